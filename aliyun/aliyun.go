@@ -15,12 +15,16 @@
 package aliyun
 
 import (
+	"context"
+	"errors"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +32,18 @@ import (
 	"github.com/casdoor/oss"
 )
 
+var (
+	_ oss.ContextStorage    = (*Client)(nil)
+	_ oss.Stater            = (*Client)(nil)
+	_ oss.OptionPutter      = (*Client)(nil)
+	_ oss.OptionLister      = (*Client)(nil)
+	_ oss.RangeGetter       = (*Client)(nil)
+	_ oss.Copier            = (*Client)(nil)
+	_ oss.UploadURLGetter   = (*Client)(nil)
+	_ oss.URLOptionGetter   = (*Client)(nil)
+	_ oss.MultipartUploader = (*Client)(nil)
+)
+
 // Client Aliyun storage
 type Client struct {
 	*aliyun.Bucket
@@ -80,7 +96,12 @@ func New(config *Config) *Client {
 
 // Get receive file with given path
 func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+	return client.GetContext(context.Background(), path)
+}
+
+// GetContext receive file with given path
+func (client Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamContext(ctx, path)
 
 	if err == nil {
 		if file, err = ioutil.TempFile("/tmp", "ali"); err == nil {
@@ -95,16 +116,59 @@ func (client Client) Get(path string) (file *os.File, err error) {
 
 // GetStream get file as stream
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	return client.Bucket.GetObject(client.ToRelativePath(path))
+	return client.GetStreamContext(context.Background(), path)
+}
+
+// GetStreamContext get file as stream
+func (client Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	readCloser, err := client.Bucket.GetObject(client.ToRelativePath(path), aliyun.WithContext(ctx))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return readCloser, nil
+}
+
+// GetRange get a range of file as stream
+func (client Client) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return client.GetRangeContext(context.Background(), path, offset, length)
+}
+
+// GetRangeContext get a range of file as stream
+func (client Client) GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	rangeOption := aliyun.NormalizedRange(strconv.FormatInt(offset, 10) + "-")
+	if length > 0 {
+		rangeOption = aliyun.Range(offset, offset+length-1)
+	}
+
+	readCloser, err := client.Bucket.GetObject(client.ToRelativePath(path), rangeOption, aliyun.WithContext(ctx))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return readCloser, nil
 }
 
 // Put store a reader into given path
 func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.PutContext(context.Background(), urlPath, reader)
+}
+
+// PutContext store a reader into given path
+func (client Client) PutContext(ctx context.Context, urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.PutWithOptionsContext(ctx, urlPath, reader, nil)
+}
+
+// PutWithOptions store a reader into given path with options
+func (client Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return client.PutWithOptionsContext(context.Background(), urlPath, reader, options)
+}
+
+// PutWithOptionsContext store a reader into given path with options
+func (client Client) PutWithOptionsContext(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
 	if seeker, ok := reader.(io.ReadSeeker); ok {
 		seeker.Seek(0, 0)
 	}
 
-	err := client.Bucket.PutObject(client.ToRelativePath(urlPath), reader, aliyun.ACL(client.Config.ACL))
+	err := client.Bucket.PutObject(client.ToRelativePath(urlPath), reader, client.putOptions(ctx, options)...)
 	now := time.Now()
 
 	return &oss.Object{
@@ -112,33 +176,198 @@ func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error)
 		Name:             filepath.Base(urlPath),
 		LastModified:     &now,
 		StorageInterface: client,
-	}, err
+	}, wrapError(err)
+}
+
+// putOptions convert PutOptions to aliyun options, the ACL of config is used if ACL isn't specified
+func (client Client) putOptions(ctx context.Context, options *oss.PutOptions) []aliyun.Option {
+	opts := []aliyun.Option{aliyun.WithContext(ctx)}
+	if options == nil {
+		options = &oss.PutOptions{}
+	}
+
+	if options.ACL != "" {
+		opts = append(opts, aliyun.ObjectACL(aliyun.ACLType(options.ACL)))
+	} else {
+		opts = append(opts, aliyun.ACL(client.Config.ACL))
+	}
+	if options.ContentType != "" {
+		opts = append(opts, aliyun.ContentType(options.ContentType))
+	}
+	if options.ContentDisposition != "" {
+		opts = append(opts, aliyun.ContentDisposition(options.ContentDisposition))
+	}
+	if options.CacheControl != "" {
+		opts = append(opts, aliyun.CacheControl(options.CacheControl))
+	}
+	if options.ContentEncoding != "" {
+		opts = append(opts, aliyun.ContentEncoding(options.ContentEncoding))
+	}
+	for key, value := range options.Metadata {
+		opts = append(opts, aliyun.Meta(key, value))
+	}
+
+	return opts
 }
 
 // Delete delete file
 func (client Client) Delete(path string) error {
-	return client.Bucket.DeleteObject(client.ToRelativePath(path))
+	return client.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext delete file
+func (client Client) DeleteContext(ctx context.Context, path string) error {
+	return wrapError(client.Bucket.DeleteObject(client.ToRelativePath(path), aliyun.WithContext(ctx)))
+}
+
+// Copy copy object from src to dst on the server side
+func (client Client) Copy(src, dst string) error {
+	return client.CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copy object from src to dst on the server side
+func (client Client) CopyContext(ctx context.Context, src, dst string) error {
+	_, err := client.Bucket.CopyObject(client.ToRelativePath(src), client.ToRelativePath(dst), aliyun.WithContext(ctx))
+	return wrapError(err)
+}
+
+// Move move object from src to dst, it's copied on the server side and then src is deleted
+func (client Client) Move(src, dst string) error {
+	return client.MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext move object from src to dst, it's copied on the server side and then src is deleted
+func (client Client) MoveContext(ctx context.Context, src, dst string) error {
+	if err := client.CopyContext(ctx, src, dst); err != nil {
+		return err
+	}
+	return client.DeleteContext(ctx, src)
 }
 
 // List list all objects under current path
 func (client Client) List(path string) ([]*oss.Object, error) {
+	return client.ListContext(context.Background(), path)
+}
+
+// ListContext list all objects under current path
+func (client Client) ListContext(ctx context.Context, path string) ([]*oss.Object, error) {
 	var objects []*oss.Object
+	options := &oss.ListOptions{}
+
+	for {
+		result, err := client.ListWithOptionsContext(ctx, path, options)
+		if err != nil {
+			return objects, err
+		}
+		objects = append(objects, result.Objects...)
 
-	results, err := client.Bucket.ListObjects(aliyun.Prefix(path))
+		if result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		options.ContinuationToken = result.NextContinuationToken
+	}
+}
 
+// ListWithOptions list a page of objects under current path
+func (client Client) ListWithOptions(path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	return client.ListWithOptionsContext(context.Background(), path, options)
+}
+
+// ListWithOptionsContext list a page of objects under current path
+func (client Client) ListWithOptionsContext(ctx context.Context, path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	if options == nil {
+		options = &oss.ListOptions{}
+	}
+
+	listOptions := []aliyun.Option{aliyun.Prefix(path), aliyun.WithContext(ctx)}
+	if options.ContinuationToken != "" {
+		listOptions = append(listOptions, aliyun.ContinuationToken(options.ContinuationToken))
+	}
+	if options.PageSize > 0 {
+		listOptions = append(listOptions, aliyun.MaxKeys(options.PageSize))
+	}
+	if options.Delimiter != "" {
+		listOptions = append(listOptions, aliyun.Delimiter(options.Delimiter))
+	}
+	if options.StartAfter != "" {
+		listOptions = append(listOptions, aliyun.StartAfter(client.ToRelativePath(options.StartAfter)))
+	}
+
+	results, err := client.Bucket.ListObjectsV2(listOptions...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	result := &oss.ListResult{}
+	for _, obj := range results.Objects {
+		lastModified := obj.LastModified
+		result.Objects = append(result.Objects, &oss.Object{
+			Path:             "/" + client.ToRelativePath(obj.Key),
+			Name:             filepath.Base(obj.Key),
+			LastModified:     &lastModified,
+			Size:             obj.Size,
+			StorageInterface: client,
+		})
+	}
+	for _, commonPrefix := range results.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, "/"+client.ToRelativePath(commonPrefix))
+	}
+	if results.IsTruncated {
+		result.NextContinuationToken = results.NextContinuationToken
+	}
+
+	return result, nil
+}
+
+// Stat get object's metadata
+func (client Client) Stat(path string) (*oss.Object, error) {
+	return client.StatContext(context.Background(), path)
+}
+
+// StatContext get object's metadata, GetObjectDetailedMeta is used as GetObjectMeta doesn't return the content type
+func (client Client) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	key := client.ToRelativePath(path)
+	header, err := client.Bucket.GetObjectDetailedMeta(key, aliyun.WithContext(ctx))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	object := &oss.Object{
+		Path:             "/" + key,
+		Name:             filepath.Base(key),
+		ContentType:      header.Get(aliyun.HTTPHeaderContentType),
+		ETag:             strings.Trim(header.Get(aliyun.HTTPHeaderEtag), `"`),
+		StorageInterface: client,
+	}
+	if size, err := strconv.ParseInt(header.Get(aliyun.HTTPHeaderContentLength), 10, 64); err == nil {
+		object.Size = size
+	}
+	if lastModified, err := http.ParseTime(header.Get(aliyun.HTTPHeaderLastModified)); err == nil {
+		object.LastModified = &lastModified
+	}
+
+	return object, nil
+}
+
+// wrapError wrap aliyun error with oss errors, so it could be checked with errors.Is
+func wrapError(err error) error {
 	if err == nil {
-		for _, obj := range results.Objects {
-			objects = append(objects, &oss.Object{
-				Path:             "/" + client.ToRelativePath(obj.Key),
-				Name:             filepath.Base(obj.Key),
-				LastModified:     &obj.LastModified,
-				Size:             obj.Size,
-				StorageInterface: client,
-			})
+		return nil
+	}
+
+	var serviceErr aliyun.ServiceError
+	if errors.As(err, &serviceErr) {
+		switch {
+		case serviceErr.Code == "NoSuchKey" || serviceErr.Code == "NoSuchBucket" || serviceErr.StatusCode == http.StatusNotFound:
+			return oss.NewError(oss.ErrNotFound, err)
+		case serviceErr.Code == "AccessDenied" || serviceErr.StatusCode == http.StatusForbidden:
+			return oss.NewError(oss.ErrPermission, err)
+		case serviceErr.Code == "FileAlreadyExists":
+			return oss.NewError(oss.ErrAlreadyExists, err)
 		}
 	}
 
-	return objects, err
+	return err
 }
 
 // GetEndpoint get endpoint, FileSystem's endpoint is /
@@ -171,10 +400,57 @@ func (client Client) ToRelativePath(urlPath string) string {
 	return strings.TrimPrefix(urlPath, "/")
 }
 
+// GetUploadURL get a signed URL to upload file with PUT request
+func (client Client) GetUploadURL(path string, expiry time.Duration, contentType string) (string, error) {
+	return client.GetUploadURLContext(context.Background(), path, expiry, contentType)
+}
+
+// GetUploadURLContext get a signed URL to upload file with PUT request
+func (client Client) GetUploadURLContext(ctx context.Context, path string, expiry time.Duration, contentType string) (string, error) {
+	if expiry <= 0 {
+		expiry = oss.DefaultURLExpiry
+	}
+	var options []aliyun.Option
+	if contentType != "" {
+		options = append(options, aliyun.ContentType(contentType))
+	}
+	return client.Bucket.SignURL(client.ToRelativePath(path), aliyun.HTTPPut, int64(expiry.Seconds()), options...)
+}
+
 // GetURL get public accessible URL
 func (client Client) GetURL(path string) (url string, err error) {
-	if client.Config.ACL == aliyun.ACLPrivate {
-		return client.Bucket.SignURL(client.ToRelativePath(path), aliyun.HTTPGet, 60*60) // 1 hour
+	return client.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get public accessible URL
+func (client Client) GetURLContext(ctx context.Context, path string) (url string, err error) {
+	return client.GetURLWithOptionsContext(ctx, path, nil)
+}
+
+// GetURLWithOptions get public accessible URL with options
+func (client Client) GetURLWithOptions(path string, options *oss.URLOptions) (string, error) {
+	return client.GetURLWithOptionsContext(context.Background(), path, options)
+}
+
+// GetURLWithOptionsContext get public accessible URL with options, it's signed if the ACL is private or the response is overridden
+func (client Client) GetURLWithOptionsContext(ctx context.Context, path string, options *oss.URLOptions) (string, error) {
+	if options == nil {
+		options = &oss.URLOptions{}
+	}
+	if options.IP != "" || options.Referer != "" {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("aliyun doesn't support IP or referer restriction of signed URL"))
+	}
+
+	var signOptions []aliyun.Option
+	if contentDisposition := options.ContentDisposition(path); contentDisposition != "" {
+		signOptions = append(signOptions, aliyun.ResponseContentDisposition(contentDisposition))
+	}
+	if options.ResponseContentType != "" {
+		signOptions = append(signOptions, aliyun.ResponseContentType(options.ResponseContentType))
+	}
+
+	if client.Config.ACL == aliyun.ACLPrivate || len(signOptions) > 0 {
+		return client.Bucket.SignURL(client.ToRelativePath(path), aliyun.HTTPGet, int64(options.GetExpiry().Seconds()), signOptions...)
 	}
 	return path, nil
 }
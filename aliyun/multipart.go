@@ -0,0 +1,162 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyun
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	aliyun "github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/casdoor/oss"
+)
+
+// InitiateUpload initiate a multipart upload
+func (client Client) InitiateUpload(path string) (string, error) {
+	return client.InitiateUploadContext(context.Background(), path)
+}
+
+// InitiateUploadContext initiate a multipart upload
+func (client Client) InitiateUploadContext(ctx context.Context, path string) (string, error) {
+	imur, err := client.Bucket.InitiateMultipartUpload(client.ToRelativePath(path), client.putOptions(ctx, &oss.PutOptions{})...)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	return imur.UploadID, nil
+}
+
+// UploadPart upload a part of a multipart upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPart(path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	return client.UploadPartContext(context.Background(), path, uploadID, number, reader)
+}
+
+// UploadPartContext upload a part of a multipart upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPartContext(ctx context.Context, path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	body, ok := reader.(io.ReadSeeker)
+	if !ok {
+		buffer, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buffer)
+	}
+	size, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	part, err := client.Bucket.UploadPart(client.multipartUpload(path, uploadID), body, size, number, aliyun.WithContext(ctx))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &oss.Part{Number: part.PartNumber, ETag: strings.Trim(part.ETag, `"`), Size: size}, nil
+}
+
+// ListParts list uploaded parts of a multipart upload
+func (client Client) ListParts(path string, uploadID string) ([]*oss.Part, error) {
+	return client.ListPartsContext(context.Background(), path, uploadID)
+}
+
+// ListPartsContext list uploaded parts of a multipart upload
+func (client Client) ListPartsContext(ctx context.Context, path string, uploadID string) ([]*oss.Part, error) {
+	var (
+		parts  []*oss.Part
+		marker int
+	)
+
+	for {
+		result, err := client.Bucket.ListUploadedParts(client.multipartUpload(path, uploadID), aliyun.PartNumberMarker(marker), aliyun.WithContext(ctx))
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		for _, part := range result.UploadedParts {
+			parts = append(parts, &oss.Part{Number: part.PartNumber, ETag: strings.Trim(part.ETag, `"`), Size: int64(part.Size)})
+		}
+
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		if marker, err = strconv.Atoi(result.NextPartNumberMarker); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CompleteUpload combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUpload(path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	return client.CompleteUploadContext(context.Background(), path, uploadID, parts)
+}
+
+// CompleteUploadContext combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUploadContext(ctx context.Context, path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	if parts == nil {
+		var err error
+		if parts, err = client.ListPartsContext(ctx, path, uploadID); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	var (
+		size        int64
+		uploadParts []aliyun.UploadPart
+	)
+	for _, part := range parts {
+		size += part.Size
+		uploadParts = append(uploadParts, aliyun.UploadPart{PartNumber: part.Number, ETag: `"` + part.ETag + `"`})
+	}
+
+	result, err := client.Bucket.CompleteMultipartUpload(client.multipartUpload(path, uploadID), uploadParts, aliyun.WithContext(ctx))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	now := time.Now()
+	return &oss.Object{
+		Path:             path,
+		Name:             filepath.Base(path),
+		LastModified:     &now,
+		Size:             size,
+		ETag:             strings.Trim(result.ETag, `"`),
+		StorageInterface: client,
+	}, nil
+}
+
+// AbortUpload abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUpload(path string, uploadID string) error {
+	return client.AbortUploadContext(context.Background(), path, uploadID)
+}
+
+// AbortUploadContext abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUploadContext(ctx context.Context, path string, uploadID string) error {
+	return wrapError(client.Bucket.AbortMultipartUpload(client.multipartUpload(path, uploadID), aliyun.WithContext(ctx)))
+}
+
+func (client Client) multipartUpload(path string, uploadID string) aliyun.InitiateMultipartUploadResult {
+	return aliyun.InitiateMultipartUploadResult{
+		Bucket:   client.Config.Bucket,
+		Key:      client.ToRelativePath(path),
+		UploadID: uploadID,
+	}
+}
@@ -17,11 +17,13 @@ package azureblob
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 
 	"io"
 	"io/ioutil"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -32,14 +34,32 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-pipeline-go/pipeline"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/casdoor/oss"
 )
 
+var (
+	_ oss.ContextStorage      = (*Client)(nil)
+	_ oss.Stater              = (*Client)(nil)
+	_ oss.OptionPutter        = (*Client)(nil)
+	_ oss.OptionLister        = (*Client)(nil)
+	_ oss.Walker              = (*Client)(nil)
+	_ oss.RangeGetter         = (*Client)(nil)
+	_ oss.Copier              = (*Client)(nil)
+	_ oss.UploadURLGetter     = (*Client)(nil)
+	_ oss.UploadHeadersGetter = (*Client)(nil)
+	_ oss.URLOptionGetter     = (*Client)(nil)
+	_ oss.MultipartUploader   = (*Client)(nil)
+)
+
 // Client azure blob storage
 type Client struct {
 	Config       *Config
+	serviceURL   azblob.ServiceURL
 	containerURL *azblob.ContainerURL
+	// err error of setting up the blob service in New, requests of the client fail with it
+	err error
 }
 
 type Config struct {
@@ -47,7 +67,34 @@ type Config struct {
 	AccessKey string //Access Keys
 	Region    string
 	Bucket    string //Container Name
-	Endpoint  string //endpoint
+	// Endpoint blob service URL, such as http://127.0.0.1:10000/devstoreaccount1 of Azurite,
+	// or a host like <account>.blob.core.chinacloudapi.cn which is requested with https,
+	// https://<account>.blob.core.windows.net is used if it's empty
+	Endpoint string
+	// ConnectionString connection string of the storage account, AccessId, AccessKey and Endpoint
+	// are taken from it if they're empty, UseDevelopmentStorage=true connects to Azurite
+	ConnectionString string
+	// SASPermissions permissions of the SAS of URLs returned by GetURL, such as "rw", "r" by default
+	SASPermissions string
+	// SASExpiry expiry of the SAS of URLs returned by GetURL, oss.DefaultURLExpiry by default
+	SASExpiry time.Duration
+	// PublicURL makes GetURL return plain blob URLs without SAS, for containers allowing public access
+	PublicURL bool
+
+	// TenantID, ClientID and ClientSecret of a service principal, requests are authorized with
+	// its tokens of Microsoft Entra ID instead of the account key, AccessId is still used for the default Endpoint
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// FederatedTokenFile file of the federated token of workload identity, which is exchanged for tokens
+	// with TenantID and ClientID, such as AZURE_FEDERATED_TOKEN_FILE set by the workload identity webhook
+	FederatedTokenFile string
+	// AccessToken a static OAuth token of azure storage, which isn't refreshed
+	AccessToken string
+	// AuthorityHost authority host of Microsoft Entra ID, https://login.microsoftonline.com by default
+	AuthorityHost string
+	// HTTPClient client of requests to the blob service and the token endpoint
+	HTTPClient *http.Client
 }
 
 var urlRegexp = regexp.MustCompile(`(https?:)?//((\w+).)+(\w+)/`)
@@ -63,23 +110,53 @@ func (client Client) ToRelativePath(urlPath string) string {
 	return strings.TrimPrefix(urlPath, "/")
 }
 
-const blobFormatString = `https://%s.blob.core.windows.net`
+// copyPollInterval interval of checking the status of a pending copy
+const copyPollInterval = 500 * time.Millisecond
 
-var (
-	ctx = context.Background() // This uses a never-expiring context.
-)
+const blobFormatString = `https://%s.blob.core.windows.net`
 
+// New create a client of azure blob storage. If the blob service couldn't be set up, e.g. the connection string
+// is malformed or no token is got, the client is still returned, but its requests and URLs fail with the error,
+// use NewWithError to get the error at once
 func New(config *Config) *Client {
-	var client = &Client{Config: config}
-
-	serviceURL, _ := GetBlobService(config)
-	client.containerURL = containerUrl(serviceURL, config)
+	client, err := NewWithError(config)
+	if err != nil {
+		u, urlErr := serviceURL(config)
+		if urlErr != nil {
+			u = &url.URL{}
+		}
+		client = &Client{Config: config, err: err}
+		client.serviceURL = azblob.NewServiceURL(*u, pipeline.NewPipeline([]pipeline.Factory{failedPolicyFactory(err), pipeline.MethodFactoryMarker()}, pipeline.Options{}))
+		client.containerURL = containerUrl(client.serviceURL, config)
+	}
 	return client
 }
 
+// NewWithError create a client of azure blob storage, the error of setting up the blob service is returned if any
+func NewWithError(config *Config) (*Client, error) {
+	serviceURL, err := GetBlobService(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Config: config, serviceURL: serviceURL, containerURL: containerUrl(serviceURL, config)}, nil
+}
+
+// failedPolicyFactory create policies failing every request with err, for clients whose blob service couldn't be set up
+func failedPolicyFactory(err error) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			return nil, err
+		}
+	})
+}
+
 func GetBlobService(config *Config) (azblob.ServiceURL, error) {
-	// Use your Storage account's name and key to create a credential object; this is used to access your account.
-	credential, err := azblob.NewSharedKeyCredential(config.AccessId, config.AccessKey)
+	if err := applyConnectionString(config); err != nil {
+		return azblob.ServiceURL{}, err
+	}
+
+	// Create a credential object with tokens or your Storage account's name and key; this is used to access your account.
+	credential, err := newCredential(config)
 	if err != nil {
 		return azblob.ServiceURL{}, err
 	}
@@ -87,16 +164,28 @@ func GetBlobService(config *Config) (azblob.ServiceURL, error) {
 	// Create a request pipeline that is used to process HTTP(S) requests and responses. It requires
 	// your account credentials. In more advanced scenarios, you can configure telemetry, retry policies,
 	// logging, and other options. Also, you can configure multiple request pipelines for different scenarios.
-	p := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	p := azblob.NewPipeline(credential, azblob.PipelineOptions{HTTPSender: config.httpSender()})
 
-	// From the Azure portal, get your Storage account blob service URL endpoint.
-	// The URL typically looks like this:
-	u, _ := url.Parse(fmt.Sprintf(blobFormatString, config.AccessId))
+	u, err := serviceURL(config)
+	if err != nil {
+		return azblob.ServiceURL{}, err
+	}
 
 	// Create an ServiceURL object that wraps the service URL and a request pipeline.
 	return azblob.NewServiceURL(*u, p), nil
 }
 
+// serviceURL get the blob service URL from Endpoint, or the URL of the account in the global azure cloud
+func serviceURL(config *Config) (*url.URL, error) {
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf(blobFormatString, config.AccessId)
+	} else if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	return url.Parse(strings.TrimSuffix(endpoint, "/"))
+}
+
 func containerUrl(serviceURL azblob.ServiceURL, config *Config) *azblob.ContainerURL {
 	// This returns a ContainerURL object that wraps the container's URL and a request pipeline (inherited from serviceURL)
 	container := serviceURL.NewContainerURL(config.Bucket)
@@ -104,6 +193,10 @@ func containerUrl(serviceURL azblob.ServiceURL, config *Config) *azblob.Containe
 }
 
 func (client Client) UploadBlob(blobName *string, blobType *string, data io.ReadSeeker) (azblob.BlockBlobURL, error) {
+	return client.UploadBlobContext(context.Background(), blobName, blobType, data)
+}
+
+func (client Client) UploadBlobContext(ctx context.Context, blobName *string, blobType *string, data io.ReadSeeker) (azblob.BlockBlobURL, error) {
 	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
 	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerUrl)
 	blobURL := client.containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
@@ -111,22 +204,34 @@ func (client Client) UploadBlob(blobName *string, blobType *string, data io.Read
 	// Upload the blob
 	_, err := blobURL.Upload(ctx, data, azblob.BlobHTTPHeaders{ContentType: *blobType}, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{})
 	if err != nil {
-		return azblob.BlockBlobURL{}, err
+		return azblob.BlockBlobURL{}, wrapError(err)
 	}
 
 	return blobURL, nil
 }
 
 func (client Client) DownloadBlob(blobName *string) (*azblob.DownloadResponse, error) {
+	return client.DownloadBlobContext(context.Background(), blobName)
+}
+
+func (client Client) DownloadBlobContext(ctx context.Context, blobName *string) (*azblob.DownloadResponse, error) {
 	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
 	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerUrl)
 	blobURL := client.containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
 
 	// Download the blob's contents and verify that it worked correctly
-	return blobURL.Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	response, err := blobURL.Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return response, nil
 }
 
 func (client Client) DeleteBlob(blobName *string) error {
+	return client.DeleteBlobContext(context.Background(), blobName)
+}
+
+func (client Client) DeleteBlobContext(ctx context.Context, blobName *string) error {
 	// Create a URL that references a to-be-created blob in your Azure Storage account's container.
 	// This returns a BlockBlobURL object that wraps the blob's URL and a request pipeline (inherited from containerUrl)
 	blobURL := client.containerURL.NewBlockBlobURL(*blobName) // Blob names can be mixed case
@@ -134,40 +239,58 @@ func (client Client) DeleteBlob(blobName *string) error {
 	// Delete the blob
 	_, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionNone, azblob.BlobAccessConditions{})
 	if err != nil {
-		return err
+		return wrapError(err)
 	}
 
 	return nil
 }
 
 func (client Client) GetListBlob() ([][]azblob.BlobItemInternal, error) {
+	return client.GetListBlobContext(context.Background())
+}
+
+// GetListBlobContext list all blobs of the container, one slice for each segment
+func (client Client) GetListBlobContext(ctx context.Context) ([][]azblob.BlobItemInternal, error) {
 	var results [][]azblob.BlobItemInternal
 
-	// List the blob(s) in our container; since a container may hold millions of blobs, this is done 1 segment at a time.
+	err := client.listSegments(ctx, "", func(blobItems []azblob.BlobItemInternal) error {
+		results = append(results, blobItems)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// listSegments list blobs whose names start with prefix, and call fn with every segment of them,
+// since a container may hold millions of blobs, this is done one segment at a time with the marker
+func (client Client) listSegments(ctx context.Context, prefix string, fn func(blobItems []azblob.BlobItemInternal) error) error {
+	options := azblob.ListBlobsSegmentOptions{Prefix: prefix}
+
 	for marker := (azblob.Marker{}); marker.NotDone(); { // The parens around Marker{} are required to avoid compiler error.
-		// Get a result segment starting with the blob indicated by the current Marker.
-		listBlob, err := client.containerURL.ListBlobsFlatSegment(ctx, marker, azblob.ListBlobsSegmentOptions{})
+		listBlob, err := client.containerURL.ListBlobsFlatSegment(ctx, marker, options)
 		if err != nil {
-			return nil, err
+			return wrapError(err)
 		}
-		// IMPORTANT: ListBlobs returns the start of the next segment; you MUST use this to get
-		// the next segment (after processing the current result segment).
+		// ListBlobs returns the start of the next segment, which must be used to get the next segment
 		marker = listBlob.NextMarker
 
-		// Process the blobs returned in this result segment (if the segment is empty, the loop body won't execute)
-		for _, blobInfo := range listBlob.Segment.BlobItems {
-			fmt.Print("Blob name: " + blobInfo.Name + "\n")
+		if err := fn(listBlob.Segment.BlobItems); err != nil {
+			return err
 		}
-
-		results = append(results, listBlob.Segment.BlobItems)
 	}
 
-	return results, nil
+	return nil
 }
 
 func (client Client) Get(path string) (file *os.File, err error) {
+	return client.GetContext(context.Background(), path)
+}
+
+func (client Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
 	path = client.ToRelativePath(path)
-	readCloser, err := client.GetStream(path)
+	readCloser, err := client.GetStreamContext(ctx, path)
 
 	if err == nil {
 		if file, err = ioutil.TempFile("/tmp", "ali"); err == nil {
@@ -188,15 +311,57 @@ func (client Client) Get(path string) (file *os.File, err error) {
 }
 
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	name := path
-	blob, err := client.DownloadBlob(&name)
+	return client.GetStreamContext(context.Background(), path)
+}
+
+func (client Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	name := client.ToRelativePath(path)
+	blob, err := client.DownloadBlobContext(ctx, &name)
 	if err != nil {
 		return nil, err
 	}
 	return blob.Response().Body, err
 }
 
+func (client Client) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return client.GetRangeContext(context.Background(), path, offset, length)
+}
+
+// GetRangeContext download a range of the blob, a count of azblob.CountToEnd reads to the end of the blob
+func (client Client) GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if length <= 0 {
+		length = azblob.CountToEnd
+	}
+
+	blobURL := client.containerURL.NewBlockBlobURL(client.ToRelativePath(path))
+	response, err := blobURL.Download(ctx, offset, length, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return response.Response().Body, nil
+}
+
 func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.PutContext(context.Background(), urlPath, reader)
+}
+
+func (client Client) PutContext(ctx context.Context, urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.PutWithOptionsContext(ctx, urlPath, reader, nil)
+}
+
+func (client Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return client.PutWithOptionsContext(context.Background(), urlPath, reader, options)
+}
+
+// PutWithOptionsContext upload the blob with options, ACL isn't supported as the access level is set per container
+func (client Client) PutWithOptionsContext(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if options == nil {
+		options = &oss.PutOptions{}
+	}
+	if options.ACL != "" {
+		return nil, oss.NewError(oss.ErrUnsupported, errors.New("azure blob doesn't support ACL per blob"))
+	}
+
 	if seeker, ok := reader.(io.ReadSeeker); ok {
 		_, err := seeker.Seek(0, 0)
 		if err != nil {
@@ -205,46 +370,434 @@ func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error)
 	}
 	urlPath = client.ToRelativePath(urlPath)
 	buffer, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
 
-	fileType := mime.TypeByExtension(path.Ext(urlPath))
+	fileType := options.ContentType
 	if fileType == "" {
-		fileType = http.DetectContentType(buffer)
+		fileType = mime.TypeByExtension(path.Ext(urlPath))
 	}
-
 	if fileType == "" {
 		fileType = http.DetectContentType(buffer)
 	}
 
-	_, err = client.UploadBlob(&urlPath, &fileType, bytes.NewReader(buffer))
+	headers := azblob.BlobHTTPHeaders{
+		ContentType:        fileType,
+		ContentDisposition: options.ContentDisposition,
+		CacheControl:       options.CacheControl,
+		ContentEncoding:    options.ContentEncoding,
+	}
+	metadata := azblob.Metadata{}
+	for key, value := range options.Metadata {
+		metadata[key] = value
+	}
+
+	blobURL := client.containerURL.NewBlockBlobURL(urlPath)
+	_, err = blobURL.Upload(ctx, bytes.NewReader(buffer), headers, metadata, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{})
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 	now := time.Now()
 
 	return &oss.Object{
-		Path:             urlPath,
+		Path:             "/" + urlPath,
 		Name:             filepath.Base(urlPath),
 		LastModified:     &now,
+		Size:             int64(len(buffer)),
+		ContentType:      fileType,
 		StorageInterface: client,
-	}, err
+	}, nil
 }
 
 func (client Client) Delete(path string) error {
+	return client.DeleteContext(context.Background(), path)
+}
+
+func (client Client) DeleteContext(ctx context.Context, path string) error {
 	path = client.ToRelativePath(path)
-	return client.DeleteBlob(&path)
+	return client.DeleteBlobContext(ctx, &path)
+}
+
+func (client Client) Copy(src, dst string) error {
+	return client.CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copy blob from src to dst with StartCopyFromURL, and wait until the copy isn't pending
+func (client Client) CopyContext(ctx context.Context, src, dst string) error {
+	srcURL := client.containerURL.NewBlobURL(client.ToRelativePath(src))
+	dstURL := client.containerURL.NewBlobURL(client.ToRelativePath(dst))
+
+	response, err := dstURL.StartCopyFromURL(ctx, srcURL.URL(), nil, azblob.ModifiedAccessConditions{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	status := response.CopyStatus()
+	for status == azblob.CopyStatusPending {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(copyPollInterval):
+		}
+
+		properties, err := dstURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+		if err != nil {
+			return wrapError(err)
+		}
+		status = properties.CopyStatus()
+	}
+
+	if status != azblob.CopyStatusSuccess {
+		return fmt.Errorf("copy blob %s to %s %s", src, dst, status)
+	}
+	return nil
+}
+
+func (client Client) Move(src, dst string) error {
+	return client.MoveContext(context.Background(), src, dst)
+}
+
+func (client Client) MoveContext(ctx context.Context, src, dst string) error {
+	if err := client.CopyContext(ctx, src, dst); err != nil {
+		return err
+	}
+	return client.DeleteContext(ctx, src)
 }
 
 func (client Client) List(path string) ([]*oss.Object, error) {
-	panic("implement me")
+	return client.ListContext(context.Background(), path)
+}
+
+// ListContext list all blobs whose names start with path
+func (client Client) ListContext(ctx context.Context, path string) ([]*oss.Object, error) {
+	var objects []*oss.Object
+
+	err := client.WalkContext(ctx, path, func(object *oss.Object) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (client Client) Walk(path string, fn oss.WalkFunc) error {
+	return client.WalkContext(context.Background(), path, fn)
+}
+
+// WalkContext call fn for every blob whose name starts with path, blobs are listed one segment at a time
+func (client Client) WalkContext(ctx context.Context, path string, fn oss.WalkFunc) error {
+	err := client.listSegments(ctx, client.ToRelativePath(path), func(blobItems []azblob.BlobItemInternal) error {
+		for _, blobInfo := range blobItems {
+			if err := fn(client.toObject(blobInfo)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == oss.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// ListWithOptions list a page of blobs under path
+func (client Client) ListWithOptions(path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	return client.ListWithOptionsContext(context.Background(), path, options)
+}
+
+// ListWithOptionsContext list a page of blobs under path with ListBlobsFlatSegment, or ListBlobsHierarchySegment
+// if a delimiter is specified, the continuation token is the marker of azure. As azure couldn't start listing
+// from a name, blobs and prefixes up to StartAfter are skipped from the first segments
+func (client Client) ListWithOptionsContext(ctx context.Context, path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	if options == nil {
+		options = &oss.ListOptions{}
+	}
+
+	segmentOptions := azblob.ListBlobsSegmentOptions{MaxResults: int32(options.PageSize)}
+	if prefix := strings.Trim(client.ToRelativePath(path), "/"); prefix != "" {
+		segmentOptions.Prefix = prefix + "/"
+	}
+	marker := azblob.Marker{}
+	var startAfter string
+	if options.ContinuationToken != "" {
+		marker.Val = &options.ContinuationToken
+	} else {
+		startAfter = client.ToRelativePath(options.StartAfter)
+	}
+
+	result := &oss.ListResult{}
+	for {
+		var segment azblob.BlobHierarchyListSegment
+		if options.Delimiter == "" {
+			listBlob, err := client.containerURL.ListBlobsFlatSegment(ctx, marker, segmentOptions)
+			if err != nil {
+				return nil, wrapError(err)
+			}
+			segment.BlobItems, marker = listBlob.Segment.BlobItems, listBlob.NextMarker
+		} else {
+			listBlob, err := client.containerURL.ListBlobsHierarchySegment(ctx, marker, options.Delimiter, segmentOptions)
+			if err != nil {
+				return nil, wrapError(err)
+			}
+			segment, marker = listBlob.Segment, listBlob.NextMarker
+		}
+
+		for _, blobInfo := range segment.BlobItems {
+			if blobInfo.Name > startAfter {
+				result.Objects = append(result.Objects, client.toObject(blobInfo))
+			}
+		}
+		for _, blobPrefix := range segment.BlobPrefixes {
+			// blobs after StartAfter may be in the prefix containing it
+			if blobPrefix.Name > startAfter || strings.HasPrefix(startAfter, blobPrefix.Name) {
+				result.CommonPrefixes = append(result.CommonPrefixes, "/"+blobPrefix.Name)
+			}
+		}
+
+		if marker.Val != nil {
+			result.NextContinuationToken = *marker.Val
+		}
+		// segments which are skipped entirely are followed by the next one, so an empty page means the end
+		if len(result.Objects)+len(result.CommonPrefixes) > 0 || result.NextContinuationToken == "" {
+			return result, nil
+		}
+	}
+}
+
+func (client Client) toObject(blobInfo azblob.BlobItemInternal) *oss.Object {
+	lastModified := blobInfo.Properties.LastModified
+	object := &oss.Object{
+		Path:             "/" + blobInfo.Name,
+		Name:             filepath.Base(blobInfo.Name),
+		LastModified:     &lastModified,
+		ETag:             strings.Trim(string(blobInfo.Properties.Etag), `"`),
+		StorageInterface: client,
+	}
+	if blobInfo.Properties.ContentLength != nil {
+		object.Size = *blobInfo.Properties.ContentLength
+	}
+	if blobInfo.Properties.ContentType != nil {
+		object.ContentType = *blobInfo.Properties.ContentType
+	}
+	return object
+}
+
+func (client Client) Stat(path string) (*oss.Object, error) {
+	return client.StatContext(context.Background(), path)
+}
+
+func (client Client) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	path = client.ToRelativePath(path)
+	blobURL := client.containerURL.NewBlockBlobURL(path)
+
+	properties, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	lastModified := properties.LastModified()
+
+	return &oss.Object{
+		Path:             "/" + path,
+		Name:             filepath.Base(path),
+		LastModified:     &lastModified,
+		Size:             properties.ContentLength(),
+		ContentType:      properties.ContentType(),
+		ETag:             strings.Trim(string(properties.ETag()), `"`),
+		StorageInterface: client,
+	}, nil
+}
+
+func (client Client) GetUploadURL(path string, expiry time.Duration, contentType string) (string, error) {
+	return client.GetUploadURLContext(context.Background(), path, expiry, contentType)
+}
+
+// GetUploadURLContext get a blob URL with a SAS permitting to create and write it, the blob is created
+// by the uploader with a Put Blob request, which has to be sent with the headers from GetUploadHeaders
+func (client Client) GetUploadURLContext(ctx context.Context, path string, expiry time.Duration, contentType string) (string, error) {
+	if expiry <= 0 {
+		expiry = oss.DefaultURLExpiry
+	}
+	return client.signURL(ctx, path, azblob.BlobSASSignatureValues{
+		ExpiryTime:  time.Now().UTC().Add(expiry),
+		Permissions: azblob.BlobSASPermissions{Create: true, Write: true}.String(),
+	})
+}
+
+// GetUploadHeaders get the headers of Put Blob requests with URLs from GetUploadURL,
+// x-ms-blob-type is required by Azure, the content type is stored from Content-Type
+func (client Client) GetUploadHeaders(contentType string) http.Header {
+	header := http.Header{}
+	header.Set("x-ms-blob-type", string(azblob.BlobBlockBlob))
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return header
 }
 
 func (client Client) GetURL(path string) (string, error) {
-	return path, nil
+	return client.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get a blob URL with a SAS of Config.SASPermissions and Config.SASExpiry,
+// or the plain blob URL if Config.PublicURL is true
+func (client Client) GetURLContext(ctx context.Context, path string) (string, error) {
+	if client.err != nil {
+		return "", client.err
+	}
+	if client.Config.PublicURL {
+		blobURL := client.containerURL.NewBlobURL(client.ToRelativePath(path)).URL()
+		return blobURL.String(), nil
+	}
+
+	permissions, err := client.sasPermissions()
+	if err != nil {
+		return "", err
+	}
+	return client.signURL(ctx, path, azblob.BlobSASSignatureValues{
+		ExpiryTime:  time.Now().UTC().Add(client.sasExpiry()),
+		Permissions: permissions,
+	})
+}
+
+func (client Client) GetURLWithOptions(path string, options *oss.URLOptions) (string, error) {
+	return client.GetURLWithOptionsContext(context.Background(), path, options)
+}
+
+// GetURLWithOptionsContext get a blob URL with a SAS of Config.SASPermissions, options.IP could be a single IP
+// or a range like 10.0.0.1-10.0.0.255, the URL is signed even if Config.PublicURL is true as long as any option is specified
+func (client Client) GetURLWithOptionsContext(ctx context.Context, path string, options *oss.URLOptions) (string, error) {
+	if options == nil || *options == (oss.URLOptions{}) {
+		return client.GetURLContext(ctx, path)
+	}
+	if options.Referer != "" {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("azure blob doesn't support referer restriction of SAS"))
+	}
+
+	var ipRange azblob.IPRange
+	if options.IP != "" {
+		start, end, _ := strings.Cut(options.IP, "-")
+		ipRange.Start = net.ParseIP(start)
+		if end != "" {
+			ipRange.End = net.ParseIP(end)
+		}
+		if ipRange.Start == nil || (end != "" && ipRange.End == nil) {
+			return "", fmt.Errorf("invalid IP range %s", options.IP)
+		}
+	}
+
+	permissions, err := client.sasPermissions()
+	if err != nil {
+		return "", err
+	}
+	expiry := options.Expiry
+	if expiry <= 0 {
+		expiry = client.sasExpiry()
+	}
+	return client.signURL(ctx, path, azblob.BlobSASSignatureValues{
+		ExpiryTime:         time.Now().UTC().Add(expiry),
+		Permissions:        permissions,
+		IPRange:            ipRange,
+		ContentDisposition: options.ContentDisposition(client.ToRelativePath(path)),
+		ContentType:        options.ResponseContentType,
+	})
+}
+
+// signURL get the blob URL of path with a SAS signed with the shared key, or a user delegation SAS if tokens are used,
+// values are completed with the blob, https is required by the SAS unless the endpoint is http, like Azurite
+func (client Client) signURL(ctx context.Context, path string, values azblob.BlobSASSignatureValues) (string, error) {
+	if client.err != nil {
+		return "", client.err
+	}
+
+	var (
+		credential azblob.StorageAccountCredential
+		err        error
+	)
+	if client.Config.usesToken() {
+		keyInfo := azblob.NewKeyInfo(time.Now().Add(-time.Minute), values.ExpiryTime)
+		credential, err = client.serviceURL.GetUserDelegationCredential(ctx, keyInfo, nil, nil)
+		err = wrapError(err)
+	} else {
+		credential, err = azblob.NewSharedKeyCredential(client.Config.AccessId, client.Config.AccessKey)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	name := client.ToRelativePath(path)
+	blobURL := client.containerURL.NewBlobURL(name).URL()
+
+	values.Protocol = azblob.SASProtocolHTTPS
+	if blobURL.Scheme == "http" {
+		values.Protocol = azblob.SASProtocolHTTPSandHTTP
+	}
+	values.ContainerName = client.Config.Bucket
+	values.BlobName = name
+	sasQueryParameters, err := values.NewSASQueryParameters(credential)
+	if err != nil {
+		return "", err
+	}
+
+	blobURL.RawQuery = sasQueryParameters.Encode()
+	return blobURL.String(), nil
+}
+
+// sasPermissions get the permissions of SAS of GetURL in the canonical order
+func (client Client) sasPermissions() (string, error) {
+	if client.Config.SASPermissions == "" {
+		return azblob.BlobSASPermissions{Read: true}.String(), nil
+	}
+
+	var permissions azblob.BlobSASPermissions
+	if err := permissions.Parse(client.Config.SASPermissions); err != nil {
+		return "", err
+	}
+	return permissions.String(), nil
+}
+
+func (client Client) sasExpiry() time.Duration {
+	if client.Config.SASExpiry > 0 {
+		return client.Config.SASExpiry
+	}
+	return oss.DefaultURLExpiry
+}
+
+// wrapError wrap azure storage error with oss errors, so it could be checked with errors.Is
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var storageErr azblob.StorageError
+	if errors.As(err, &storageErr) {
+		switch storageErr.ServiceCode() {
+		case azblob.ServiceCodeBlobNotFound, azblob.ServiceCodeContainerNotFound, azblob.ServiceCodeResourceNotFound:
+			return oss.NewError(oss.ErrNotFound, err)
+		case azblob.ServiceCodeBlobAlreadyExists:
+			return oss.NewError(oss.ErrAlreadyExists, err)
+		case azblob.ServiceCodeAuthenticationFailed, azblob.ServiceCodeInsufficientAccountPermissions:
+			return oss.NewError(oss.ErrPermission, err)
+		}
+
+		if response := storageErr.Response(); response != nil {
+			switch response.StatusCode {
+			case http.StatusNotFound:
+				return oss.NewError(oss.ErrNotFound, err)
+			case http.StatusUnauthorized, http.StatusForbidden:
+				return oss.NewError(oss.ErrPermission, err)
+			}
+		}
+	}
+
+	return err
 }
 
 func (client Client) GetEndpoint() string {
-	if client.Config.Endpoint != "" {
-		return client.Config.Endpoint
+	if u, err := serviceURL(client.Config); err == nil {
+		return u.String()
 	}
-	return fmt.Sprintf(blobFormatString, client.Config.AccessId)
+	return client.Config.Endpoint
 }
@@ -16,10 +16,19 @@ package azureblob
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/casdoor/oss"
 	"github.com/casdoor/oss/tests"
 )
 
@@ -55,3 +64,320 @@ func TestClientPut2(t *testing.T) {
 func TestClientDelete(t *testing.T) {
 	fmt.Println(client.Delete("test.png"))
 }
+
+// listBlobsPages pages of the fake List Blobs response, the marker of the next page is its index
+var listBlobsPages = []string{
+	`<Blob><Name>logs/1.txt</Name><Properties><Last-Modified>Mon, 02 Jan 2006 15:04:05 GMT</Last-Modified><Content-Length>5</Content-Length></Properties></Blob>`,
+	`<Blob><Name>logs/2.txt</Name><Properties><Last-Modified>Mon, 02 Jan 2006 15:04:05 GMT</Last-Modified><Content-Length>7</Content-Length></Properties></Blob>`,
+}
+
+func TestList(t *testing.T) {
+	var prefixes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("comp") != "list" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		prefixes = append(prefixes, query.Get("prefix"))
+
+		page, nextMarker := listBlobsPages[0], "1"
+		if query.Get("marker") == "1" {
+			page, nextMarker = listBlobsPages[1], ""
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>%s</Blobs><NextMarker>%s</NextMarker></EnumerationResults>`, page, nextMarker)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL + "/container")
+	containerURL := azblob.NewContainerURL(*u, azblob.NewPipeline(azblob.NewAnonymousCredential(), azblob.PipelineOptions{}))
+	client := &Client{Config: &Config{Bucket: "container"}, containerURL: &containerURL}
+
+	objects, err := client.List("/logs/")
+	if err != nil {
+		t.Fatalf("No error should happen when list blobs, but got %v", err)
+	}
+	if len(objects) != 2 || objects[0].Path != "/logs/1.txt" || objects[1].Name != "2.txt" || objects[1].Size != 7 || objects[0].LastModified == nil {
+		t.Errorf("Blobs of all segments should be listed, but got %v", objects)
+	}
+	for _, prefix := range prefixes {
+		if prefix != "logs/" {
+			t.Errorf("Blobs should be listed with prefix logs/, but got %v", prefix)
+		}
+	}
+}
+
+func TestAllWithAzurite(t *testing.T) {
+	azurite := newFakeAzurite(t)
+	client := New(&Config{ConnectionString: azurite.ConnectionString(), Bucket: "container"})
+	if endpoint := client.GetEndpoint(); endpoint != azurite.URL+"/"+DevelopmentAccountName {
+		t.Errorf("Endpoint should be taken from connection string, but got %v", endpoint)
+	}
+	tests.TestAll(client, t)
+}
+
+func TestListWithOptionsWithAzurite(t *testing.T) {
+	azurite := newFakeAzurite(t)
+	client := New(&Config{ConnectionString: azurite.ConnectionString(), Bucket: "container"})
+	for _, name := range []string{"/logs/a.txt", "/logs/b/1.txt", "/logs/b/2.txt", "/logs/c.txt", "other.txt"} {
+		if object, err := client.Put(name, strings.NewReader("sample")); err != nil {
+			t.Fatalf("No error should happen when put blob, but got %v", err)
+		} else if object.Path != "/"+strings.TrimPrefix(name, "/") {
+			t.Errorf("Path of put blob should start with a slash like listed ones, but got %v", object.Path)
+		}
+	}
+	if object, err := client.Stat("logs/a.txt"); err != nil || object.Path != "/logs/a.txt" {
+		t.Errorf("Path of stat blob should start with a slash like listed ones, but got %+v %v", object, err)
+	}
+
+	listAll := func(options *oss.ListOptions) []string {
+		var items []string
+		for {
+			result, err := client.ListWithOptions("/logs", options)
+			if err != nil {
+				t.Fatalf("No error should happen when list blobs with options, but got %v", err)
+			}
+			if options.PageSize > 0 && len(result.Objects)+len(result.CommonPrefixes) > options.PageSize {
+				t.Errorf("Page should contain at most %v items, but got %v and %v", options.PageSize, result.Objects, result.CommonPrefixes)
+			}
+			for _, object := range result.Objects {
+				items = append(items, object.Path)
+			}
+			items = append(items, result.CommonPrefixes...)
+			if result.NextContinuationToken == "" {
+				return items
+			}
+			options.ContinuationToken = result.NextContinuationToken
+		}
+	}
+
+	if items := listAll(&oss.ListOptions{PageSize: 1}); strings.Join(items, ",") != "/logs/a.txt,/logs/b/1.txt,/logs/b/2.txt,/logs/c.txt" {
+		t.Errorf("All blobs under logs should be listed page by page, but got %v", items)
+	}
+	if items := listAll(&oss.ListOptions{PageSize: 1, Delimiter: "/"}); strings.Join(items, ",") != "/logs/a.txt,/logs/b/,/logs/c.txt" {
+		t.Errorf("Blobs under logs/b should be grouped into a common prefix, but got %v", items)
+	}
+	if items := listAll(&oss.ListOptions{PageSize: 1, Delimiter: "/", StartAfter: "/logs/b/1.txt"}); strings.Join(items, ",") != "/logs/b/,/logs/c.txt" {
+		t.Errorf("Blobs up to StartAfter should be skipped, but got %v", items)
+	}
+	if items := listAll(&oss.ListOptions{PageSize: 1, StartAfter: "/logs/b/2.txt"}); strings.Join(items, ",") != "/logs/c.txt" {
+		t.Errorf("Blobs up to StartAfter should be skipped, but got %v", items)
+	}
+
+	var walked int
+	if err := client.Walk("/logs", func(object *oss.Object) error { walked++; return oss.SkipAll }); err != nil || walked != 1 {
+		t.Errorf("Walk should stop without an error after SkipAll, but got %v after %v blobs", err, walked)
+	}
+}
+
+func TestMultipartUploadWithAzurite(t *testing.T) {
+	azurite := newFakeAzurite(t)
+	client := New(&Config{ConnectionString: azurite.ConnectionString(), Bucket: "container"})
+
+	uploadID, err := client.InitiateUpload("/multipart.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when initiate upload, but got %v", err)
+	}
+	if parts, err := client.ListParts("/multipart.txt", uploadID); err != nil || len(parts) != 0 {
+		t.Errorf("No part should be listed before uploading, but got %v, %v", parts, err)
+	}
+	for number, content := range map[int]string{2: "world", 1: "hello "} {
+		if _, err := client.UploadPart("/multipart.txt", uploadID, number, strings.NewReader(content)); err != nil {
+			t.Fatalf("No error should happen when upload part %v, but got %v", number, err)
+		}
+	}
+	if object, err := client.CompleteUpload("/multipart.txt", uploadID, nil); err != nil {
+		t.Fatalf("No error should happen when complete upload, but got %v", err)
+	} else if object.Size != 11 || object.Path != "/multipart.txt" {
+		t.Errorf("Completed blob should be /multipart.txt of 11 bytes, but got %v %v", object.Path, object.Size)
+	}
+
+	stream, err := client.GetStream("/multipart.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get completed blob, but got %v", err)
+	}
+	defer stream.Close()
+	if content, _ := ioutil.ReadAll(stream); string(content) != "hello world" {
+		t.Errorf("Blocks should be committed in order, but got %q", content)
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	config := &Config{ConnectionString: "AccountName", Bucket: "container"}
+	if _, err := NewWithError(config); err == nil {
+		t.Errorf("There should be an error when create client with malformed connection string")
+	}
+
+	// requests of the client from New fail instead of panicking
+	client := New(&Config{ConnectionString: "AccountName", Bucket: "container"})
+	if _, err := client.Put("/sample.txt", strings.NewReader("sample")); err == nil {
+		t.Errorf("There should be an error when put with client of malformed connection string")
+	}
+	if _, err := client.List("/"); err == nil {
+		t.Errorf("There should be an error when list with client of malformed connection string")
+	}
+	if _, err := client.GetURL("/sample.txt"); err == nil {
+		t.Errorf("There should be an error when get URL with client of malformed connection string")
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	for _, c := range []struct {
+		config   Config
+		endpoint string
+	}{
+		{Config{AccessId: "account", AccessKey: DevelopmentAccountKey}, "https://account.blob.core.windows.net"},
+		{Config{AccessId: "account", AccessKey: DevelopmentAccountKey, Endpoint: "account.blob.core.chinacloudapi.cn"}, "https://account.blob.core.chinacloudapi.cn"},
+		{Config{AccessId: "account", AccessKey: DevelopmentAccountKey, Endpoint: "http://127.0.0.1:10000/account/"}, "http://127.0.0.1:10000/account"},
+		{Config{ConnectionString: "UseDevelopmentStorage=true"}, "http://127.0.0.1:10000/devstoreaccount1"},
+		{Config{ConnectionString: "DefaultEndpointsProtocol=https;AccountName=account;AccountKey=" + DevelopmentAccountKey + ";EndpointSuffix=core.usgovcloudapi.net"}, "https://account.blob.core.usgovcloudapi.net"},
+	} {
+		config := c.config
+		if _, err := GetBlobService(&config); err != nil {
+			t.Errorf("No error should happen when get blob service of %+v, but got %v", c.config, err)
+		} else if endpoint := (Client{Config: &config}).GetEndpoint(); endpoint != c.endpoint {
+			t.Errorf("Endpoint of %+v should be %v, but got %v", c.config, c.endpoint, endpoint)
+		}
+	}
+
+	if _, err := GetBlobService(&Config{ConnectionString: "AccountName=account"}); err == nil {
+		t.Errorf("There should be an error when connection string has no account key")
+	}
+}
+
+func TestGetURLWithSAS(t *testing.T) {
+	azurite := newFakeAzurite(t)
+	client := New(&Config{ConnectionString: azurite.ConnectionString(), Bucket: "container", SASPermissions: "wr", SASExpiry: time.Minute})
+	if _, err := client.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Fatalf("No error should happen when put sample file, but got %v", err)
+	}
+
+	rawURL, err := client.GetURL("/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get URL, but got %v", err)
+	}
+	u, _ := url.Parse(rawURL)
+	if query := u.Query(); query.Get("sig") == "" || query.Get("sp") != "rw" {
+		t.Errorf("URL should be signed with permissions rw, but got %v", rawURL)
+	} else if expiry, _ := time.Parse(time.RFC3339, query.Get("se")); time.Until(expiry) > time.Minute {
+		t.Errorf("SAS should expire in a minute, but got %v", query.Get("se"))
+	}
+	if resp, err := http.Get(rawURL); err != nil {
+		t.Errorf("No error should happen when get blob with SAS URL, but got %v", err)
+	} else if resp.Body.Close(); resp.StatusCode != http.StatusOK {
+		t.Errorf("Blob should be downloaded with SAS URL, but got status %v", resp.StatusCode)
+	}
+
+	client.Config.PublicURL = true
+	if rawURL, err := client.GetURL("/sample.txt"); err != nil {
+		t.Errorf("No error should happen when get public URL, but got %v", err)
+	} else if rawURL != azurite.URL+"/"+DevelopmentAccountName+"/container/sample.txt" {
+		t.Errorf("Public URL should be the plain blob URL, but got %v", rawURL)
+	}
+
+	client.Config.PublicURL = false
+	client.Config.SASPermissions = "z"
+	if _, err := client.GetURL("/sample.txt"); err == nil {
+		t.Errorf("There should be an error when get URL with invalid permissions")
+	}
+}
+
+func TestGetUploadURLWithAzurite(t *testing.T) {
+	azurite := newFakeAzurite(t)
+	client := New(&Config{ConnectionString: azurite.ConnectionString(), Bucket: "container"})
+	rawURL, err := client.GetUploadURL("/upload.txt", time.Minute, "text/plain")
+	if err != nil {
+		t.Fatalf("No error should happen when get upload URL, but got %v", err)
+	}
+	upload := func(rawURL string, header http.Header) int {
+		req, _ := http.NewRequest(http.MethodPut, rawURL, strings.NewReader("sample"))
+		req.Header = header
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("No error should happen when upload with URL, but got %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := upload(rawURL, http.Header{"Content-Type": {"text/plain"}}); status != http.StatusBadRequest {
+		t.Errorf("Upload without x-ms-blob-type should be rejected, but got status %v", status)
+	}
+	if status := upload(strings.Replace(rawURL, "sig=", "sig=x", 1), oss.GetUploadHeaders(client, "text/plain")); status != http.StatusForbidden {
+		t.Errorf("Upload with a wrong signature should be rejected, but got status %v", status)
+	}
+	if status := upload(rawURL, oss.GetUploadHeaders(client, "text/plain")); status != http.StatusCreated {
+		t.Errorf("Upload with the headers should succeed, but got status %v", status)
+	} else if object, err := client.Stat("/upload.txt"); err != nil || object.ContentType != "text/plain" {
+		t.Errorf("Uploaded blob should have the content type, but got %+v %v", object, err)
+	}
+
+	wrongKey := New(&Config{AccessId: DevelopmentAccountName, AccessKey: "d3Jvbmc=", Endpoint: azurite.URL + "/" + DevelopmentAccountName, Bucket: "container"})
+	if _, err := wrongKey.Put("/sample.txt", strings.NewReader("sample")); !errors.Is(err, oss.ErrPermission) {
+		t.Errorf("Requests signed with a wrong key should be rejected, but got %v", err)
+	}
+}
+
+func TestTokenCredential(t *testing.T) {
+	azurite := newFakeAzuriteTLS(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("federated-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	endpoint := newFakeTokenEndpoint(t, azurite, "secret", "federated-token", 3600)
+	azurite.acceptToken("static-token")
+
+	for name, config := range map[string]*Config{
+		"client secret":       {TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+		"workload identity":   {TenantID: "tenant", ClientID: "client", FederatedTokenFile: tokenFile},
+		"static access token": {AccessToken: "static-token"},
+	} {
+		config.AccessId = DevelopmentAccountName
+		config.Endpoint = azurite.URL + "/" + DevelopmentAccountName
+		config.Bucket = "container"
+		config.AuthorityHost = endpoint.URL
+		config.HTTPClient = azurite.Client()
+		client := New(config)
+
+		if _, err := client.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+			t.Errorf("No error should happen when put with %v, but got %v", name, err)
+		}
+		if stream, err := client.GetStream("/sample.txt"); err != nil {
+			t.Errorf("No error should happen when get with %v, but got %v", name, err)
+		} else if content, _ := ioutil.ReadAll(stream); string(content) != "sample" {
+			t.Errorf("Blob put with %v should be sample, but got %q", name, content)
+		}
+
+		// URLs are signed with user delegation keys, as there is no account key
+		if rawURL, err := client.GetURL("/sample.txt"); err != nil {
+			t.Errorf("No error should happen when get URL with %v, but got %v", name, err)
+		} else if u, _ := url.Parse(rawURL); u.Query().Get("skoid") == "" || u.Query().Get("sig") == "" {
+			t.Errorf("URL should be signed with user delegation key with %v, but got %v", name, rawURL)
+		}
+	}
+	if endpoint.Issued() != 2 {
+		t.Errorf("A token should be issued for client secret and workload identity each, but got %v", endpoint.Issued())
+	}
+
+	if _, err := GetBlobService(&Config{AccessId: DevelopmentAccountName, Endpoint: azurite.URL + "/" + DevelopmentAccountName,
+		TenantID: "tenant", ClientID: "client", ClientSecret: "wrong", AuthorityHost: endpoint.URL, HTTPClient: azurite.Client()}); err == nil {
+		t.Errorf("There should be an error when the client secret is wrong")
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	azurite := newFakeAzuriteTLS(t)
+	endpoint := newFakeTokenEndpoint(t, azurite, "secret", "", 1)
+	client := New(&Config{AccessId: DevelopmentAccountName, Endpoint: azurite.URL + "/" + DevelopmentAccountName, Bucket: "container",
+		TenantID: "tenant", ClientID: "client", ClientSecret: "secret", AuthorityHost: endpoint.URL, HTTPClient: azurite.Client()})
+
+	for deadline := time.Now().Add(5 * time.Second); endpoint.Issued() < 3 && time.Now().Before(deadline); {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if endpoint.Issued() < 3 {
+		t.Fatalf("Token should be refreshed before it expires, but got %v tokens", endpoint.Issued())
+	}
+	if _, err := client.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Errorf("No error should happen when put with refreshed token, but got %v", err)
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azureblob
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+type fakeBlob struct {
+	data         []byte
+	contentType  string
+	etag         string
+	lastModified time.Time
+}
+
+// fakeAzurite a local stand-in of Azurite, which serves the blob APIs used by Client
+// with path-style URLs like http://127.0.0.1:<port>/devstoreaccount1/<container>/<blob>
+type fakeAzurite struct {
+	*httptest.Server
+
+	mutex  sync.Mutex
+	blobs  map[string]*fakeBlob
+	blocks map[string]map[string][]byte
+	tokens map[string]bool
+}
+
+func newFakeAzurite(t *testing.T) *fakeAzurite {
+	return startFakeAzurite(t, httptest.NewServer)
+}
+
+// newFakeAzuriteTLS start azurite with https, which is required by token credentials,
+// requests should be sent with azurite.Client() trusting its certificate
+func newFakeAzuriteTLS(t *testing.T) *fakeAzurite {
+	return startFakeAzurite(t, httptest.NewTLSServer)
+}
+
+func startFakeAzurite(t *testing.T, start func(handler http.Handler) *httptest.Server) *fakeAzurite {
+	azurite := &fakeAzurite{blobs: map[string]*fakeBlob{}, blocks: map[string]map[string][]byte{}, tokens: map[string]bool{}}
+	azurite.Server = start(http.HandlerFunc(azurite.serveHTTP))
+	t.Cleanup(azurite.Close)
+	return azurite
+}
+
+// acceptToken make azurite accept requests with the OAuth token
+func (azurite *fakeAzurite) acceptToken(token string) {
+	azurite.mutex.Lock()
+	defer azurite.mutex.Unlock()
+	azurite.tokens[token] = true
+}
+
+// ConnectionString get the connection string of the development account served by azurite
+func (azurite *fakeAzurite) ConnectionString() string {
+	return fmt.Sprintf("UseDevelopmentStorage=true;BlobEndpoint=%s/%s", azurite.URL, DevelopmentAccountName)
+}
+
+func (azurite *fakeAzurite) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	azurite.mutex.Lock()
+	defer azurite.mutex.Unlock()
+
+	query := r.URL.Query()
+	authorization := r.Header.Get("Authorization")
+	bearer := azurite.tokens[strings.TrimPrefix(authorization, "Bearer ")]
+	if !bearer && !verifySharedKey(r) && !verifySAS(r) {
+		writeFakeError(w, http.StatusForbidden, "AuthenticationFailed")
+		return
+	}
+	if query.Get("comp") == "userdelegationkey" {
+		// user delegation keys are only given to requests authorized with tokens
+		if !bearer {
+			writeFakeError(w, http.StatusForbidden, "AuthenticationFailed")
+			return
+		}
+		now := time.Now().UTC()
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><UserDelegationKey><SignedOid>oid</SignedOid><SignedTid>tid</SignedTid><SignedStart>%s</SignedStart><SignedExpiry>%s</SignedExpiry><SignedService>b</SignedService><SignedVersion>2020-10-02</SignedVersion><Value>%s</Value></UserDelegationKey>`,
+			now.Format(time.RFC3339), now.Add(time.Hour).Format(time.RFC3339), DevelopmentAccountKey)
+		return
+	}
+
+	container, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"+DevelopmentAccountName+"/"), "/")
+	switch {
+	case r.Method == http.MethodGet && query.Get("comp") == "list":
+		azurite.listBlobs(w, container, query)
+	case r.Method == http.MethodPut && query.Get("comp") == "block":
+		data, _ := ioutil.ReadAll(r.Body)
+		if azurite.blocks[name] == nil {
+			azurite.blocks[name] = map[string][]byte{}
+		}
+		azurite.blocks[name][query.Get("blockid")] = data
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && query.Get("comp") == "blocklist":
+		azurite.commitBlockList(w, r, name)
+	case r.Method == http.MethodGet && query.Get("comp") == "blocklist":
+		azurite.getBlockList(w, name)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-copy-source") != "":
+		source, err := url.Parse(r.Header.Get("x-ms-copy-source"))
+		if err != nil {
+			writeFakeError(w, http.StatusBadRequest, "InvalidHeaderValue")
+			return
+		}
+		_, sourceName, _ := strings.Cut(strings.TrimPrefix(source.Path, "/"+DevelopmentAccountName+"/"), "/")
+		blob, ok := azurite.blobs[sourceName]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "CannotVerifyCopySource")
+			return
+		}
+		azurite.putBlob(w, name, blob.data, blob.contentType, http.StatusAccepted)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("x-ms-blob-type") != string(azblob.BlobBlockBlob) {
+			writeFakeError(w, http.StatusBadRequest, "MissingRequiredHeader")
+			return
+		}
+		contentType := r.Header.Get("x-ms-blob-content-type")
+		if contentType == "" {
+			contentType = r.Header.Get("Content-Type")
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		azurite.putBlob(w, name, data, contentType, http.StatusCreated)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		azurite.getBlob(w, r, name)
+	case r.Method == http.MethodDelete:
+		if _, ok := azurite.blobs[name]; !ok {
+			writeFakeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(azurite.blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeFakeError(w, http.StatusBadRequest, "UnsupportedHttpVerb")
+	}
+}
+
+// verifySharedKey check the SharedKey signature of the request with the key of the development account
+func verifySharedKey(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "SharedKey ") {
+		return false
+	}
+
+	credential, _ := azblob.NewSharedKeyCredential(DevelopmentAccountName, DevelopmentAccountKey)
+	request := r.Clone(r.Context())
+	var expected string
+	policy := credential.New(pipeline.PolicyFunc(func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+		expected = request.Header.Get("Authorization")
+		return nil, nil
+	}), nil)
+	policy.Do(r.Context(), pipeline.Request{Request: request})
+	return authorization == expected
+}
+
+// verifySAS check the signature and expiry of the SAS of the request, which is signed with the key
+// of the development account or a user delegation key given by azurite, and its permission for the method
+func verifySAS(r *http.Request) bool {
+	query := r.URL.Query()
+	if query.Get("sig") == "" {
+		return false
+	}
+	sas := azblob.NewBlobURLParts(*r.URL).SAS
+	if time.Now().After(sas.ExpiryTime()) {
+		return false
+	}
+	permission := map[string]string{http.MethodGet: "r", http.MethodHead: "r", http.MethodPut: "w", http.MethodDelete: "d"}[r.Method]
+	if !strings.Contains(sas.Permissions(), permission) {
+		return false
+	}
+
+	var credential azblob.StorageAccountCredential
+	if query.Get("skoid") != "" {
+		credential = azblob.NewUserDelegationCredential(DevelopmentAccountName, azblob.UserDelegationKey{
+			SignedOid:     query.Get("skoid"),
+			SignedTid:     sas.SignedTid(),
+			SignedStart:   sas.SignedStart(),
+			SignedExpiry:  sas.SignedExpiry(),
+			SignedService: sas.SignedService(),
+			SignedVersion: sas.SignedVersion(),
+			Value:         DevelopmentAccountKey,
+		})
+	} else {
+		credential, _ = azblob.NewSharedKeyCredential(DevelopmentAccountName, DevelopmentAccountKey)
+	}
+	container, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"+DevelopmentAccountName+"/"), "/")
+	expected, err := azblob.BlobSASSignatureValues{
+		Version:            sas.Version(),
+		Protocol:           sas.Protocol(),
+		StartTime:          sas.StartTime(),
+		ExpiryTime:         sas.ExpiryTime(),
+		Permissions:        sas.Permissions(),
+		IPRange:            sas.IPRange(),
+		Identifier:         sas.Identifier(),
+		ContainerName:      container,
+		BlobName:           name,
+		CacheControl:       sas.CacheControl(),
+		ContentDisposition: sas.ContentDisposition(),
+		ContentEncoding:    sas.ContentEncoding(),
+		ContentLanguage:    sas.ContentLanguage(),
+		ContentType:        sas.ContentType(),
+	}.NewSASQueryParameters(credential)
+	return err == nil && expected.Signature() == sas.Signature()
+}
+
+func (azurite *fakeAzurite) putBlob(w http.ResponseWriter, name string, data []byte, contentType string, status int) {
+	sum := md5.Sum(data)
+	blob := &fakeBlob{
+		data:         data,
+		contentType:  contentType,
+		etag:         `"0x` + strings.ToUpper(hex.EncodeToString(sum[:8])) + `"`,
+		lastModified: time.Now().UTC().Truncate(time.Second),
+	}
+	azurite.blobs[name] = blob
+	delete(azurite.blocks, name)
+
+	w.Header().Set("ETag", blob.etag)
+	w.Header().Set("Last-Modified", blob.lastModified.Format(http.TimeFormat))
+	w.Header().Set("x-ms-copy-status", "success")
+	w.WriteHeader(status)
+}
+
+func (azurite *fakeAzurite) getBlob(w http.ResponseWriter, r *http.Request, name string) {
+	blob, ok := azurite.blobs[name]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "BlobNotFound")
+		return
+	}
+
+	data, status := blob.data, http.StatusOK
+	rangeHeader := r.Header.Get("x-ms-range")
+	if rangeHeader == "" {
+		rangeHeader = r.Header.Get("Range")
+	}
+	if rangeHeader != "" {
+		var start, end int
+		if n, _ := fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end); n == 0 || start >= len(data) {
+			writeFakeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		} else if n == 1 || end >= len(data) {
+			end = len(data) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data, status = data[start:end+1], http.StatusPartialContent
+	}
+
+	query := r.URL.Query()
+	contentType := blob.contentType
+	if rsct := query.Get("rsct"); rsct != "" {
+		contentType = rsct
+	}
+	if rscd := query.Get("rscd"); rscd != "" {
+		w.Header().Set("Content-Disposition", rscd)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("ETag", blob.etag)
+	w.Header().Set("Last-Modified", blob.lastModified.Format(http.TimeFormat))
+	w.Header().Set("x-ms-blob-type", "BlockBlob")
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (azurite *fakeAzurite) listBlobs(w http.ResponseWriter, container string, query url.Values) {
+	// names of blobs and prefixes ending with the delimiter, the marker is the last of them
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	items := map[string]bool{}
+	for name := range azurite.blobs {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		item := name
+		if i := strings.Index(name[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			item = name[:len(prefix)+i+len(delimiter)]
+		}
+		if item > query.Get("marker") {
+			items[item] = true
+		}
+	}
+	var names []string
+	for item := range items {
+		names = append(names, item)
+	}
+	sort.Strings(names)
+
+	var nextMarker string
+	if maxResults, _ := strconv.Atoi(query.Get("maxresults")); maxResults > 0 && len(names) > maxResults {
+		names, nextMarker = names[:maxResults], names[maxResults-1]
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="%s"><Blobs>`, container)
+	for _, name := range names {
+		blob, ok := azurite.blobs[name]
+		if !ok || delimiter != "" && strings.HasSuffix(name, delimiter) {
+			builder.WriteString("<BlobPrefix><Name>")
+			xml.EscapeText(&builder, []byte(name))
+			builder.WriteString("</Name></BlobPrefix>")
+			continue
+		}
+		builder.WriteString("<Blob><Name>")
+		xml.EscapeText(&builder, []byte(name))
+		fmt.Fprintf(&builder, "</Name><Properties><Last-Modified>%s</Last-Modified><Etag>%s</Etag><Content-Length>%d</Content-Length><Content-Type>%s</Content-Type><BlobType>BlockBlob</BlobType></Properties></Blob>",
+			blob.lastModified.Format(http.TimeFormat), strings.Trim(blob.etag, `"`), len(blob.data), blob.contentType)
+	}
+	fmt.Fprintf(&builder, "</Blobs><NextMarker>%s</NextMarker></EnumerationResults>", nextMarker)
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(builder.String()))
+}
+
+func (azurite *fakeAzurite) commitBlockList(w http.ResponseWriter, r *http.Request, name string) {
+	var blockList struct {
+		Latest []string `xml:"Latest"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&blockList); err != nil {
+		writeFakeError(w, http.StatusBadRequest, "InvalidXmlDocument")
+		return
+	}
+
+	var data []byte
+	for _, id := range blockList.Latest {
+		block, ok := azurite.blocks[name][id]
+		if !ok {
+			writeFakeError(w, http.StatusBadRequest, "InvalidBlockList")
+			return
+		}
+		data = append(data, block...)
+	}
+	azurite.putBlob(w, name, data, r.Header.Get("x-ms-blob-content-type"), http.StatusCreated)
+}
+
+func (azurite *fakeAzurite) getBlockList(w http.ResponseWriter, name string) {
+	blocks, ok := azurite.blocks[name]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "BlobNotFound")
+		return
+	}
+
+	var builder strings.Builder
+	builder.WriteString(`<?xml version="1.0" encoding="utf-8"?><BlockList><CommittedBlocks></CommittedBlocks><UncommittedBlocks>`)
+	for id, data := range blocks {
+		fmt.Fprintf(&builder, "<Block><Name>%s</Name><Size>%d</Size></Block>", id, len(data))
+	}
+	builder.WriteString("</UncommittedBlocks></BlockList>")
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(builder.String()))
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+// fakeTokenEndpoint a stand-in of the token endpoint of Microsoft Entra ID, which issues tokens accepted by azurite
+// to the client of tenant "tenant" and client ID "client", authenticated with secret or assertion
+type fakeTokenEndpoint struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	issued    int
+	expiresIn int
+}
+
+func newFakeTokenEndpoint(t *testing.T, azurite *fakeAzurite, secret, assertion string, expiresIn int) *fakeTokenEndpoint {
+	endpoint := &fakeTokenEndpoint{expiresIn: expiresIn}
+	endpoint.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost || r.URL.Path != "/tenant/oauth2/v2.0/token" || r.ParseForm() != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_request"}`)
+			return
+		}
+		form := r.PostForm
+		authenticated := form.Get("client_secret") == secret && secret != "" ||
+			form.Get("client_assertion") == assertion && assertion != "" && form.Get("client_assertion_type") == "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+		if form.Get("grant_type") != "client_credentials" || form.Get("client_id") != "client" || form.Get("scope") != storageScope || !authenticated {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"client isn't authenticated"}`)
+			return
+		}
+
+		endpoint.mutex.Lock()
+		endpoint.issued++
+		token := fmt.Sprintf("token-%d", endpoint.issued)
+		endpoint.mutex.Unlock()
+		azurite.acceptToken(token)
+		fmt.Fprintf(w, `{"token_type":"Bearer","expires_in":%d,"access_token":"%s"}`, endpoint.expiresIn, token)
+	}))
+	t.Cleanup(endpoint.Close)
+	return endpoint
+}
+
+// Issued get the count of issued tokens
+func (endpoint *fakeTokenEndpoint) Issued() int {
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+	return endpoint.issued
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azureblob
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Well-known account and key of Azurite and the Azure storage emulator
+const (
+	DevelopmentAccountName = "devstoreaccount1"
+	DevelopmentAccountKey  = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	developmentEndpoint    = "http://127.0.0.1:10000/" + DevelopmentAccountName
+)
+
+// parseConnectionString parse a connection string like
+// DefaultEndpointsProtocol=https;AccountName=<account>;AccountKey=<key>;EndpointSuffix=core.windows.net
+// into its settings, the keys are case insensitive
+func parseConnectionString(connectionString string) (map[string]string, error) {
+	settings := map[string]string{}
+	for _, segment := range strings.Split(connectionString, ";") {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(segment, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid segment %q of connection string", segment)
+		}
+		settings[strings.ToLower(key)] = value
+	}
+	return settings, nil
+}
+
+// applyConnectionString fill AccessId, AccessKey and Endpoint of config with its ConnectionString if they're empty
+func applyConnectionString(config *Config) error {
+	if config.ConnectionString == "" {
+		return nil
+	}
+	settings, err := parseConnectionString(config.ConnectionString)
+	if err != nil {
+		return err
+	}
+
+	var (
+		accountName = settings["accountname"]
+		accountKey  = settings["accountkey"]
+		endpoint    = settings["blobendpoint"]
+	)
+	if strings.EqualFold(settings["usedevelopmentstorage"], "true") {
+		accountName, accountKey = DevelopmentAccountName, DevelopmentAccountKey
+		if endpoint == "" {
+			endpoint = developmentEndpoint
+		}
+	}
+	if endpoint == "" && accountName != "" {
+		protocol, suffix := settings["defaultendpointsprotocol"], settings["endpointsuffix"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		if suffix == "" {
+			suffix = "core.windows.net"
+		}
+		endpoint = fmt.Sprintf("%s://%s.blob.%s", protocol, accountName, suffix)
+	}
+
+	if config.AccessId == "" {
+		config.AccessId = accountName
+	}
+	if config.AccessKey == "" {
+		config.AccessKey = accountKey
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = endpoint
+	}
+	if config.AccessId == "" || config.AccessKey == "" {
+		return errors.New("connection string should contain AccountName and AccountKey")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azureblob
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+const (
+	// defaultAuthorityHost authority host of Microsoft Entra ID in the global azure cloud
+	defaultAuthorityHost = "https://login.microsoftonline.com"
+	// storageScope scope of tokens to access azure storage
+	storageScope = "https://storage.azure.com/.default"
+	// tokenRetryInterval interval of retrying when a token fails to be refreshed, the old token is kept meanwhile
+	tokenRetryInterval = 30 * time.Second
+)
+
+// usesToken reports whether config authorizes requests with OAuth tokens instead of the account key
+func (config *Config) usesToken() bool {
+	return config.AccessToken != "" || config.ClientSecret != "" || config.FederatedTokenFile != ""
+}
+
+// newCredential create the credential of requests, a token credential is created if any token setting is specified,
+// otherwise the shared key credential of AccessId and AccessKey
+func newCredential(config *Config) (azblob.Credential, error) {
+	switch {
+	case config.AccessToken != "":
+		return azblob.NewTokenCredential(config.AccessToken, nil), nil
+	case config.ClientSecret != "" || config.FederatedTokenFile != "":
+		if config.TenantID == "" || config.ClientID == "" {
+			return nil, errors.New("TenantID and ClientID are required to get tokens")
+		}
+
+		source := tokenSource{config: config}
+		token, expiresIn, err := source.token(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		// the refresher is called immediately by azblob, the token got above is used until it's about to expire
+		refreshAfter := refreshInterval(expiresIn)
+		return azblob.NewTokenCredential(token, func(credential azblob.TokenCredential) time.Duration {
+			if refreshAfter > 0 {
+				interval := refreshAfter
+				refreshAfter = 0
+				return interval
+			}
+
+			token, expiresIn, err := source.token(context.Background())
+			if err != nil {
+				return tokenRetryInterval
+			}
+			credential.SetToken(token)
+			return refreshInterval(expiresIn)
+		}), nil
+	}
+
+	return azblob.NewSharedKeyCredential(config.AccessId, config.AccessKey)
+}
+
+// refreshInterval refresh tokens 5 minutes before they expire, or at half of their lifetime if they're short-lived
+func refreshInterval(expiresIn time.Duration) time.Duration {
+	if expiresIn > 10*time.Minute {
+		return expiresIn - 5*time.Minute
+	}
+	if expiresIn/2 > 0 {
+		return expiresIn / 2
+	}
+	return tokenRetryInterval
+}
+
+// tokenSource get tokens from the token endpoint of Microsoft Entra ID with the client credentials flow,
+// the client is authenticated with either the client secret or the federated token of workload identity
+type tokenSource struct {
+	config *Config
+}
+
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+func (source tokenSource) token(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", source.config.ClientID)
+	form.Set("scope", storageScope)
+	if source.config.FederatedTokenFile != "" {
+		// the federated token is rotated by kubernetes, so it's read on every request
+		assertion, err := ioutil.ReadFile(source.config.FederatedTokenFile)
+		if err != nil {
+			return "", 0, err
+		}
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	} else {
+		form.Set("client_secret", source.config.ClientSecret)
+	}
+
+	authorityHost := source.config.AuthorityHost
+	if authorityHost == "" {
+		authorityHost = defaultAuthorityHost
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authorityHost, "/"), url.PathEscape(source.config.TenantID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := source.config.httpClient().Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	var result tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("decode token response with status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", 0, fmt.Errorf("get token with status %d: %s %s", resp.StatusCode, result.Error, result.ErrorDescription)
+	}
+
+	expiresIn, _ := result.ExpiresIn.Int64()
+	return result.AccessToken, time.Duration(expiresIn) * time.Second, nil
+}
+
+func (config *Config) httpClient() *http.Client {
+	if config.HTTPClient != nil {
+		return config.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// httpSender send requests of the azblob pipeline with HTTPClient, azblob's default sender is used if it's nil
+func (config *Config) httpSender() pipeline.Factory {
+	if config.HTTPClient == nil {
+		return nil
+	}
+	return pipeline.FactoryFunc(func(next pipeline.Policy, po *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, request pipeline.Request) (pipeline.Response, error) {
+			resp, err := config.HTTPClient.Do(request.WithContext(ctx))
+			if err != nil {
+				return nil, pipeline.NewError(err, "HTTP request failed")
+			}
+			return pipeline.NewHTTPResponse(resp), nil
+		}
+	})
+}
@@ -0,0 +1,186 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/casdoor/oss"
+)
+
+// InitiateUpload initiate a multipart upload, which is a list of blocks staged with StageBlock.
+// Azure has no upload session, the returned ID is a random prefix of the block IDs
+func (client Client) InitiateUpload(urlPath string) (string, error) {
+	return client.InitiateUploadContext(context.Background(), urlPath)
+}
+
+// InitiateUploadContext initiate a multipart upload, which is a list of blocks staged with StageBlock.
+// Azure has no upload session, the returned ID is a random prefix of the block IDs
+func (client Client) InitiateUploadContext(ctx context.Context, urlPath string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// UploadPart stage a block of the upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPart(urlPath string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	return client.UploadPartContext(context.Background(), urlPath, uploadID, number, reader)
+}
+
+// UploadPartContext stage a block of the upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPartContext(ctx context.Context, urlPath string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	body, ok := reader.(io.ReadSeeker)
+	if !ok {
+		buffer, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buffer)
+	}
+	size, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	id := blockID(uploadID, number)
+	blobURL := client.containerURL.NewBlockBlobURL(client.ToRelativePath(urlPath))
+	_, err = blobURL.StageBlock(ctx, id, body, azblob.LeaseAccessConditions{}, nil, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &oss.Part{Number: number, ETag: id, Size: size}, nil
+}
+
+// ListParts list the uncommitted blocks of the upload, the ETag of a part is its block ID
+func (client Client) ListParts(urlPath string, uploadID string) ([]*oss.Part, error) {
+	return client.ListPartsContext(context.Background(), urlPath, uploadID)
+}
+
+// ListPartsContext list the uncommitted blocks of the upload, the ETag of a part is its block ID
+func (client Client) ListPartsContext(ctx context.Context, urlPath string, uploadID string) ([]*oss.Part, error) {
+	blobURL := client.containerURL.NewBlockBlobURL(client.ToRelativePath(urlPath))
+	blockList, err := blobURL.GetBlockList(ctx, azblob.BlockListUncommitted, azblob.LeaseAccessConditions{})
+	if err != nil {
+		// the blob doesn't exist before any block is staged
+		if err = wrapError(err); errors.Is(err, oss.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var parts []*oss.Part
+	for _, block := range blockList.UncommittedBlocks {
+		if number, ok := blockNumber(uploadID, block.Name); ok {
+			parts = append(parts, &oss.Part{Number: number, ETag: block.Name, Size: block.Size})
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+// CompleteUpload commit the blocks of parts as the blob, all staged blocks of the upload are committed if parts is nil
+func (client Client) CompleteUpload(urlPath string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	return client.CompleteUploadContext(context.Background(), urlPath, uploadID, parts)
+}
+
+// CompleteUploadContext commit the blocks of parts as the blob, all staged blocks of the upload are committed if parts is nil
+func (client Client) CompleteUploadContext(ctx context.Context, urlPath string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	if parts == nil {
+		var err error
+		if parts, err = client.ListPartsContext(ctx, urlPath, uploadID); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	var (
+		size     int64
+		blockIDs []string
+	)
+	for _, part := range parts {
+		size += part.Size
+		blockIDs = append(blockIDs, blockID(uploadID, part.Number))
+	}
+
+	urlPath = client.ToRelativePath(urlPath)
+	headers := azblob.BlobHTTPHeaders{ContentType: mime.TypeByExtension(path.Ext(urlPath))}
+	blobURL := client.containerURL.NewBlockBlobURL(urlPath)
+	response, err := blobURL.CommitBlockList(ctx, blockIDs, headers, azblob.Metadata{}, azblob.BlobAccessConditions{}, azblob.DefaultAccessTier, nil, azblob.ClientProvidedKeyOptions{}, azblob.ImmutabilityPolicyOptions{})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	lastModified := response.LastModified()
+
+	return &oss.Object{
+		Path:             "/" + urlPath,
+		Name:             filepath.Base(urlPath),
+		LastModified:     &lastModified,
+		Size:             size,
+		ContentType:      headers.ContentType,
+		ETag:             strings.Trim(string(response.ETag()), `"`),
+		StorageInterface: client,
+	}, nil
+}
+
+// AbortUpload does nothing, azure doesn't delete uncommitted blocks on request,
+// they are garbage collected if the blob isn't committed within 7 days
+func (client Client) AbortUpload(urlPath string, uploadID string) error {
+	return client.AbortUploadContext(context.Background(), urlPath, uploadID)
+}
+
+// AbortUploadContext does nothing, azure doesn't delete uncommitted blocks on request,
+// they are garbage collected if the blob isn't committed within 7 days
+func (client Client) AbortUploadContext(ctx context.Context, urlPath string, uploadID string) error {
+	return ctx.Err()
+}
+
+// blockID get the block ID of part number of an upload, block IDs of a blob must be in the same length
+func blockID(uploadID string, number int) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%05d", uploadID, number)))
+}
+
+// blockNumber get the part number from a block ID, ok is false if the block doesn't belong to the upload
+func blockNumber(uploadID string, blockID string) (number int, ok bool) {
+	decoded, err := base64.StdEncoding.DecodeString(blockID)
+	if err != nil {
+		return 0, false
+	}
+	suffix := strings.TrimPrefix(string(decoded), uploadID+"-")
+	if suffix == string(decoded) {
+		return 0, false
+	}
+	number, err = strconv.Atoi(suffix)
+	return number, err == nil
+}
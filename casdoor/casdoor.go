@@ -15,6 +15,7 @@
 package casdoor
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -28,6 +29,11 @@ import (
 	"github.com/casdoor/oss"
 )
 
+var (
+	_ oss.ContextStorage = (*Client)(nil)
+	_ oss.OptionPutter   = (*Client)(nil)
+)
+
 type Client struct {
 	*casdoorsdk.Client
 	Config       *Config
@@ -68,7 +74,11 @@ func New(config *Config) *Client {
 }
 
 func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+	return client.GetContext(context.Background(), path)
+}
+
+func (client Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamContext(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +90,7 @@ func (client Client) Get(path string) (file *os.File, err error) {
 		}
 	}(readCloser)
 
-	if file, err = os.CreateTemp(os.TempDir(), "casdoor"); err != nil {
-		defer readCloser.Close()
+	if file, err = os.CreateTemp(os.TempDir(), "casdoor"); err == nil {
 		_, err = io.Copy(file, readCloser)
 		file.Seek(0, 0)
 	}
@@ -91,11 +100,15 @@ func (client Client) Get(path string) (file *os.File, err error) {
 }
 
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	path, err := client.GetURL(path)
+	return client.GetStreamContext(context.Background(), path)
+}
+
+func (client Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	path, err := client.GetURLContext(ctx, path)
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest("GET", path, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -104,26 +117,30 @@ func (client Client) GetStream(path string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	//defer func(Body io.ReadCloser) {
-	//	err := Body.Close()
-	//	if err != nil {
-	//		return
-	//	}
-	//}(resp.Body)
-
-	respBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusForbidden {
-		return nil, fmt.Errorf("%s", string(respBytes))
+		defer resp.Body.Close()
+		respBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		err = fmt.Errorf("%s", string(respBytes))
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, oss.NewError(oss.ErrNotFound, err)
+		}
+		return nil, err
 	}
 
 	return resp.Body, nil
 }
 
 func (client Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err error) {
+	return client.PutContext(context.Background(), urlPath, reader)
+}
+
+// PutContext store a reader into given path, casdoor sdk doesn't accept a context,
+// so the context is only checked before uploading
+func (client Client) PutContext(ctx context.Context, urlPath string, reader io.Reader) (r *oss.Object, err error) {
 	if seeker, ok := reader.(io.ReadSeeker); ok {
 		seeker.Seek(0, 0)
 	}
@@ -133,6 +150,10 @@ func (client Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err e
 
 	//urlPath = client.transUrl(urlPath)
 
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	fileUrl, name, err := client.UploadResource("casdoor-oss", "", "", client.transUrl(urlPath), buffer)
 
 	now := time.Now()
@@ -144,16 +165,40 @@ func (client Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err e
 	}, err
 }
 
+// PutWithOptions store a reader into given path, ErrUnsupported is returned if any option is set as casdoor resources couldn't store them
+func (client Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return client.PutWithOptionsContext(context.Background(), urlPath, reader, options)
+}
+
+// PutWithOptionsContext store a reader into given path, ErrUnsupported is returned if any option is set as casdoor resources couldn't store them
+func (client Client) PutWithOptionsContext(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if err := options.CheckSupported("casdoor"); err != nil {
+		return nil, err
+	}
+	return client.PutContext(ctx, urlPath, reader)
+}
+
 func (client Client) Delete(path string) error {
+	return client.DeleteContext(context.Background(), path)
+}
+
+func (client Client) DeleteContext(ctx context.Context, path string) error {
 	name, err := client.getName(path)
 	if err != nil {
 		return err
 	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
 	_, err = client.DeleteResource(&casdoorsdk.Resource{Application: client.ApplicationName, Provider: client.Config.Provider, Name: name})
 	return err
 }
 
 func (client Client) List(rawPath string) ([]*oss.Object, error) {
+	return client.ListContext(context.Background(), rawPath)
+}
+
+func (client Client) ListContext(ctx context.Context, rawPath string) ([]*oss.Object, error) {
 	var objects []*oss.Object
 	rawPath, err := client.getName(rawPath)
 	if err != nil {
@@ -164,6 +209,10 @@ func (client Client) List(rawPath string) ([]*oss.Object, error) {
 		rawPath = rawPath[1:]
 	}
 
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	resourceList, err := client.GetResources(client.Config.OrganizationName, "casdoor-oss", "provider", client.Config.Provider, "Direct", rawPath)
 	if err != nil {
 		return nil, err
@@ -199,6 +248,10 @@ func (client Client) getName(rawPath string) (string, error) {
 }
 
 func (client Client) GetURL(path string) (url string, err error) {
+	return client.GetURLContext(context.Background(), path)
+}
+
+func (client Client) GetURLContext(ctx context.Context, path string) (url string, err error) {
 	return client.CustomDomain + client.transUrl(path), nil
 }
 
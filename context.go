@@ -0,0 +1,71 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// ContextStorage define common API to operate storage with a context,
+// cancellation and deadlines of the context are passed to the underlying requests
+type ContextStorage interface {
+	GetContext(ctx context.Context, path string) (*os.File, error)
+	GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error)
+	PutContext(ctx context.Context, path string, reader io.Reader) (*Object, error)
+	DeleteContext(ctx context.Context, path string) error
+	ListContext(ctx context.Context, path string) ([]*Object, error)
+	GetURLContext(ctx context.Context, path string) (string, error)
+	GetEndpoint() string
+}
+
+// WithContext adapt a ContextStorage to StorageInterface, every operation is run with given context
+func WithContext(ctx context.Context, storage ContextStorage) StorageInterface {
+	return contextStorage{ctx: ctx, storage: storage}
+}
+
+type contextStorage struct {
+	ctx     context.Context
+	storage ContextStorage
+}
+
+func (s contextStorage) Get(path string) (*os.File, error) {
+	return s.storage.GetContext(s.ctx, path)
+}
+
+func (s contextStorage) GetStream(path string) (io.ReadCloser, error) {
+	return s.storage.GetStreamContext(s.ctx, path)
+}
+
+func (s contextStorage) Put(path string, reader io.Reader) (*Object, error) {
+	return s.storage.PutContext(s.ctx, path, reader)
+}
+
+func (s contextStorage) Delete(path string) error {
+	return s.storage.DeleteContext(s.ctx, path)
+}
+
+func (s contextStorage) List(path string) ([]*Object, error) {
+	return s.storage.ListContext(s.ctx, path)
+}
+
+func (s contextStorage) GetURL(path string) (string, error) {
+	return s.storage.GetURLContext(s.ctx, path)
+}
+
+func (s contextStorage) GetEndpoint() string {
+	return s.storage.GetEndpoint()
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss
+
+import (
+	"context"
+	"io"
+)
+
+// Copier is implemented by storages which could copy and move objects on the server side,
+// without downloading and uploading them
+type Copier interface {
+	Copy(src, dst string) error
+	CopyContext(ctx context.Context, src, dst string) error
+	Move(src, dst string) error
+	MoveContext(ctx context.Context, src, dst string) error
+}
+
+// Copy copy object from src to dst, with the storage's native copy if it's a Copier,
+// otherwise the object is streamed from src to dst
+func Copy(ctx context.Context, storage StorageInterface, src, dst string) error {
+	if copier, ok := storage.(Copier); ok {
+		return copier.CopyContext(ctx, src, dst)
+	}
+	return streamCopy(ctx, storage, src, dst)
+}
+
+// Move move object from src to dst, with the storage's native move if it's a Copier,
+// otherwise the object is streamed from src to dst and then src is deleted
+func Move(ctx context.Context, storage StorageInterface, src, dst string) error {
+	if copier, ok := storage.(Copier); ok {
+		return copier.MoveContext(ctx, src, dst)
+	}
+	if err := streamCopy(ctx, storage, src, dst); err != nil {
+		return err
+	}
+	if contextStorage, ok := storage.(ContextStorage); ok {
+		return contextStorage.DeleteContext(ctx, src)
+	}
+	return storage.Delete(src)
+}
+
+func streamCopy(ctx context.Context, storage StorageInterface, src, dst string) error {
+	contextStorage, ok := storage.(ContextStorage)
+
+	var (
+		reader io.ReadCloser
+		err    error
+	)
+	if ok {
+		reader, err = contextStorage.GetStreamContext(ctx, src)
+	} else {
+		reader, err = storage.GetStream(src)
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if ok {
+		_, err = contextStorage.PutContext(ctx, dst, reader)
+	} else {
+		_, err = storage.Put(dst, reader)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss
+
+import (
+	"errors"
+)
+
+// Errors returned by storages, check them with errors.Is
+var (
+	ErrNotFound      = errors.New("oss: object not found")
+	ErrPermission    = errors.New("oss: permission denied")
+	ErrAlreadyExists = errors.New("oss: object already exists")
+	ErrUnsupported   = errors.New("oss: operation not supported")
+)
+
+// Error wraps the native error of a storage with one of the errors above,
+// the native error is still available with errors.As
+type Error struct {
+	Kind error
+	Err  error
+}
+
+// NewError wrap err with kind, returns nil if err is nil
+func NewError(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/casdoor/oss"
+)
+
+func TestNewError(t *testing.T) {
+	if oss.NewError(oss.ErrNotFound, nil) != nil {
+		t.Errorf("NewError should return nil for nil error")
+	}
+
+	_, nativeErr := os.Open("/not/exist/file")
+	err := oss.NewError(oss.ErrNotFound, nativeErr)
+
+	if !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("error should be ErrNotFound, but got %v", err)
+	}
+
+	if errors.Is(err, oss.ErrPermission) {
+		t.Errorf("error should not be ErrPermission")
+	}
+
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("native error should be available with errors.As")
+	}
+}
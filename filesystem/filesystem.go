@@ -15,15 +15,33 @@
 package filesystem
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/casdoor/oss"
 )
 
+var (
+	_ oss.ContextStorage    = (*FileSystem)(nil)
+	_ oss.Stater            = (*FileSystem)(nil)
+	_ oss.OptionPutter      = (*FileSystem)(nil)
+	_ oss.OptionLister      = (*FileSystem)(nil)
+	_ oss.Walker            = (*FileSystem)(nil)
+	_ oss.RangeGetter       = (*FileSystem)(nil)
+	_ oss.Copier            = (*FileSystem)(nil)
+	_ oss.MultipartUploader = (*FileSystem)(nil)
+)
+
+// defaultPageSize page size of ListWithOptions if it isn't specified
+const defaultPageSize = 1000
+
 // FileSystem file system storage
 type FileSystem struct {
 	Base string
@@ -49,67 +67,461 @@ func (fileSystem FileSystem) GetFullPath(path string) string {
 
 // Get receive file with given path
 func (fileSystem FileSystem) Get(path string) (*os.File, error) {
-	return os.Open(fileSystem.GetFullPath(path))
+	return fileSystem.GetContext(context.Background(), path)
+}
+
+// GetContext receive file with given path
+func (fileSystem FileSystem) GetContext(ctx context.Context, path string) (*os.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fileSystem.GetFullPath(path))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return file, nil
 }
 
 // GetStream get file as stream
 func (fileSystem FileSystem) GetStream(path string) (io.ReadCloser, error) {
-	return os.Open(fileSystem.GetFullPath(path))
+	return fileSystem.GetStreamContext(context.Background(), path)
+}
+
+// GetStreamContext get file as stream
+func (fileSystem FileSystem) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fileSystem.GetFullPath(path))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return file, nil
+}
+
+// GetRange get a range of file as stream
+func (fileSystem FileSystem) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return fileSystem.GetRangeContext(context.Background(), path, offset, length)
+}
+
+// GetRangeContext get a range of file as stream
+func (fileSystem FileSystem) GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(fileSystem.GetFullPath(path))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length <= 0 {
+		return file, nil
+	}
+	return limitedReadCloser{Reader: io.LimitReader(file, length), Closer: file}, nil
+}
+
+// limitedReadCloser reads from a limited reader and closes the underlying file
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
 }
 
 // Put store a reader into given path
 func (fileSystem FileSystem) Put(path string, reader io.Reader) (*oss.Object, error) {
+	return fileSystem.PutContext(context.Background(), path, reader)
+}
+
+// PutContext store a reader into given path
+func (fileSystem FileSystem) PutContext(ctx context.Context, path string, reader io.Reader) (*oss.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	var (
 		fullpath = fileSystem.GetFullPath(path)
 		err      = os.MkdirAll(filepath.Dir(fullpath), os.ModePerm)
 	)
 
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	dst, err := os.Create(fullpath)
 
 	if err == nil {
+		defer dst.Close()
 		if seeker, ok := reader.(io.ReadSeeker); ok {
 			seeker.Seek(0, 0)
 		}
-		_, err = io.Copy(dst, reader)
+		_, err = io.Copy(dst, contextReader{ctx: ctx, reader: reader})
+	}
+
+	return &oss.Object{Path: path, Name: filepath.Base(path), StorageInterface: fileSystem}, wrapError(err)
+}
+
+// PutWithOptions store a reader into given path, ErrUnsupported is returned if any option is set as there is nowhere to store them
+func (fileSystem FileSystem) PutWithOptions(path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return fileSystem.PutWithOptionsContext(context.Background(), path, reader, options)
+}
+
+// PutWithOptionsContext store a reader into given path, ErrUnsupported is returned if any option is set as there is nowhere to store them
+func (fileSystem FileSystem) PutWithOptionsContext(ctx context.Context, path string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if err := options.CheckSupported("file system"); err != nil {
+		return nil, err
 	}
+	return fileSystem.PutContext(ctx, path, reader)
+}
 
-	return &oss.Object{Path: path, Name: filepath.Base(path), StorageInterface: fileSystem}, err
+// contextReader stops reading once the context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
 }
 
 // Delete delete file
 func (fileSystem FileSystem) Delete(path string) error {
-	return os.Remove(fileSystem.GetFullPath(path))
+	return fileSystem.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext delete file
+func (fileSystem FileSystem) DeleteContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return wrapError(os.Remove(fileSystem.GetFullPath(path)))
+}
+
+// Copy copy file from src to dst
+func (fileSystem FileSystem) Copy(src, dst string) error {
+	return fileSystem.CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copy file from src to dst, copying a file onto itself leaves it as it is
+func (fileSystem FileSystem) CopyContext(ctx context.Context, src, dst string) error {
+	if srcPath := fileSystem.GetFullPath(src); filepath.Clean(srcPath) == filepath.Clean(fileSystem.GetFullPath(dst)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := os.Stat(srcPath)
+		return wrapError(err)
+	}
+
+	file, err := fileSystem.GetStreamContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fileSystem.PutContext(ctx, dst, file)
+	return err
+}
+
+// Move move file from src to dst with os.Rename
+func (fileSystem FileSystem) Move(src, dst string) error {
+	return fileSystem.MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext move file from src to dst with os.Rename
+func (fileSystem FileSystem) MoveContext(ctx context.Context, src, dst string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fullpath := fileSystem.GetFullPath(dst)
+	if err := os.MkdirAll(filepath.Dir(fullpath), os.ModePerm); err != nil {
+		return wrapError(err)
+	}
+	return wrapError(os.Rename(fileSystem.GetFullPath(src), fullpath))
 }
 
 // List list all objects under current path
 func (fileSystem FileSystem) List(path string) ([]*oss.Object, error) {
-	var (
-		objects  []*oss.Object
-		fullpath = fileSystem.GetFullPath(path)
-	)
+	return fileSystem.ListContext(context.Background(), path)
+}
+
+// ListContext list all objects under current path
+func (fileSystem FileSystem) ListContext(ctx context.Context, path string) ([]*oss.Object, error) {
+	var objects []*oss.Object
 
-	filepath.Walk(fullpath, func(path string, info os.FileInfo, err error) error {
+	err := fileSystem.WalkContext(ctx, path, func(object *oss.Object) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// Walk call fn for every object under current path
+func (fileSystem FileSystem) Walk(path string, fn oss.WalkFunc) error {
+	return fileSystem.WalkContext(context.Background(), path, fn)
+}
+
+// WalkContext call fn for every object under current path, parts of multipart uploads aren't objects
+func (fileSystem FileSystem) WalkContext(ctx context.Context, path string, fn oss.WalkFunc) error {
+	fullpath := fileSystem.GetFullPath(path)
+
+	err := filepath.Walk(fullpath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if err == nil && info.IsDir() && path == filepath.Join(fileSystem.Base, uploadsDir) {
+			return filepath.SkipDir
+		}
 		if path == fullpath {
 			return nil
 		}
 
 		if err == nil && !info.IsDir() {
-			modTime := info.ModTime()
-			objects = append(objects, &oss.Object{
-				Path:             strings.TrimPrefix(path, fileSystem.Base),
-				Name:             info.Name(),
-				LastModified:     &modTime,
-				StorageInterface: fileSystem,
-			})
+			return fn(fileSystem.toObject(strings.TrimPrefix(path, fileSystem.Base), info))
 		}
 		return nil
 	})
+	if err == oss.SkipAll {
+		return nil
+	}
+	return err
+}
 
-	return objects, nil
+// toObject convert the file info of path to object
+func (fileSystem FileSystem) toObject(path string, info os.FileInfo) *oss.Object {
+	modTime := info.ModTime()
+	return &oss.Object{
+		Path:             path,
+		Name:             info.Name(),
+		LastModified:     &modTime,
+		StorageInterface: fileSystem,
+	}
+}
+
+// ListWithOptions list a page of objects under current path
+func (fileSystem FileSystem) ListWithOptions(path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	return fileSystem.ListWithOptionsContext(context.Background(), path, options)
+}
+
+// ListWithOptionsContext list a page of objects under current path, objects are sorted by path,
+// the continuation token is the path of the last object or common prefix in the page.
+// Directories are read in the order of paths from the token, so a page doesn't read the whole tree
+func (fileSystem FileSystem) ListWithOptionsContext(ctx context.Context, path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	if options == nil {
+		options = &oss.ListOptions{}
+	}
+
+	// the token is after StartAfter, though a common prefix containing StartAfter is sorted before it
+	startAfter := options.StartAfter
+	if options.ContinuationToken != "" {
+		startAfter = options.ContinuationToken
+	}
+	// skip objects of the common prefix which ended the last page
+	var skipPrefix string
+	if options.Delimiter != "" && strings.HasSuffix(startAfter, options.Delimiter) {
+		skipPrefix = startAfter
+	}
+	pageSize := options.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	fullpath := fileSystem.GetFullPath(path)
+	dirPath := strings.TrimPrefix(fullpath, fileSystem.Base)
+
+	var (
+		result = &oss.ListResult{}
+		count  int
+		last   string
+	)
+	add := func(key string, object *oss.Object) error {
+		if count == pageSize {
+			result.NextContinuationToken = last
+			return errPageFull
+		}
+		if object != nil {
+			result.Objects = append(result.Objects, object)
+		} else {
+			result.CommonPrefixes = append(result.CommonPrefixes, key)
+		}
+		count++
+		last = key
+		return nil
+	}
+
+	err := fileSystem.walkSorted(ctx, fullpath, startAfter, skipPrefix, func(objectPath string, entry os.DirEntry) error {
+		key, grouped := objectPath, false
+		if options.Delimiter != "" {
+			rest := strings.TrimPrefix(strings.TrimPrefix(objectPath, dirPath), "/")
+			if i := strings.Index(rest, options.Delimiter); i >= 0 {
+				key, grouped = objectPath[:len(objectPath)-len(rest)+i+len(options.Delimiter)], true
+			}
+		}
+
+		if entry.IsDir() {
+			// all objects in the directory belong to the same common prefix if the delimiter is in its path
+			if !grouped {
+				return nil
+			}
+			if key != last {
+				ok, err := fileSystem.hasObjects(ctx, filepath.Join(fileSystem.Base, objectPath), startAfter)
+				if err != nil {
+					return err
+				}
+				if ok {
+					if err := add(key, nil); err != nil {
+						return err
+					}
+				}
+			}
+			return filepath.SkipDir
+		}
+
+		if grouped {
+			if key == last {
+				return nil
+			}
+			return add(key, nil)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		return add(key, fileSystem.toObject(objectPath, info))
+	})
+	if err != nil && err != errPageFull {
+		return nil, err
+	}
+	return result, nil
+}
+
+// errPageFull stop walking when a page of ListWithOptions is full
+var errPageFull = errors.New("page is full")
+
+// hasObjects check whether there is an object after startAfter in the directory fullpath
+func (fileSystem FileSystem) hasObjects(ctx context.Context, fullpath string, startAfter string) (bool, error) {
+	var found bool
+	err := fileSystem.walkSorted(ctx, fullpath, startAfter, "", func(objectPath string, entry os.DirEntry) error {
+		if entry.IsDir() {
+			return nil
+		}
+		found = true
+		return errPageFull
+	})
+	if err != nil && err != errPageFull {
+		return false, err
+	}
+	return found, nil
+}
+
+// walkSorted call fn for files and directories under fullpath in the order of their paths, paths of directories
+// end with a separator, and fn could return filepath.SkipDir to skip one. Files whose path isn't after startAfter
+// or starts with skipPrefix are skipped, so are directories which contain only such files and the directory of uploads
+func (fileSystem FileSystem) walkSorted(ctx context.Context, fullpath string, startAfter string, skipPrefix string, fn func(objectPath string, entry os.DirEntry) error) error {
+	entries, err := os.ReadDir(fullpath)
+	if err != nil {
+		// errors of reading directories are ignored like List
+		return nil
+	}
+
+	objectPaths := make(map[os.DirEntry]string, len(entries))
+	for _, entry := range entries {
+		objectPath := strings.TrimPrefix(filepath.Join(fullpath, entry.Name()), fileSystem.Base)
+		if entry.IsDir() {
+			objectPath += string(filepath.Separator)
+		}
+		objectPaths[entry] = objectPath
+	}
+	// a directory is sorted by its path with the separator, which is the prefix of all files in it
+	sort.Slice(entries, func(i, j int) bool { return objectPaths[entries[i]] < objectPaths[entries[j]] })
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		objectPath := objectPaths[entry]
+		if skipPrefix != "" && strings.HasPrefix(objectPath, skipPrefix) {
+			continue
+		}
+		if entry.IsDir() && filepath.Join(fullpath, entry.Name()) == filepath.Join(fileSystem.Base, uploadsDir) {
+			continue
+		}
+		if !entry.IsDir() {
+			if objectPath <= startAfter {
+				continue
+			}
+			if err := fn(objectPath, entry); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// all files in the directory are before startAfter
+		if objectPath <= startAfter && !strings.HasPrefix(startAfter, objectPath) {
+			continue
+		}
+		if err := fn(objectPath, entry); err != nil {
+			if err == filepath.SkipDir {
+				continue
+			}
+			return err
+		}
+		if err := fileSystem.walkSorted(ctx, filepath.Join(fullpath, entry.Name()), startAfter, skipPrefix, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat get object's metadata
+func (fileSystem FileSystem) Stat(path string) (*oss.Object, error) {
+	return fileSystem.StatContext(context.Background(), path)
+}
+
+// StatContext get object's metadata
+func (fileSystem FileSystem) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fileSystem.GetFullPath(path))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	modTime := info.ModTime()
+
+	return &oss.Object{
+		Path:             path,
+		Name:             info.Name(),
+		LastModified:     &modTime,
+		Size:             info.Size(),
+		ContentType:      mime.TypeByExtension(filepath.Ext(path)),
+		StorageInterface: fileSystem,
+	}, nil
+}
+
+// wrapError wrap os error with oss errors, so it could be checked with errors.Is
+func wrapError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		return oss.NewError(oss.ErrNotFound, err)
+	case errors.Is(err, os.ErrPermission):
+		return oss.NewError(oss.ErrPermission, err)
+	case errors.Is(err, os.ErrExist):
+		return oss.NewError(oss.ErrAlreadyExists, err)
+	}
+	return err
 }
 
 // GetEndpoint get endpoint, FileSystem's endpoint is /
@@ -119,5 +531,10 @@ func (fileSystem FileSystem) GetEndpoint() string {
 
 // GetURL get public accessible URL
 func (fileSystem FileSystem) GetURL(path string) (url string, err error) {
+	return fileSystem.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get public accessible URL
+func (fileSystem FileSystem) GetURLContext(ctx context.Context, path string) (url string, err error) {
 	return path, nil
 }
@@ -15,8 +15,16 @@
 package filesystem
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/casdoor/oss"
 	"github.com/casdoor/oss/tests"
 )
 
@@ -24,3 +32,245 @@ func TestAll(t *testing.T) {
 	fileSystem := New("/tmp")
 	tests.TestAll(fileSystem, t)
 }
+
+func TestAllWithContext(t *testing.T) {
+	fileSystem := New("/tmp")
+	tests.TestAll(oss.WithContext(context.Background(), fileSystem), t)
+}
+
+func TestCanceledContext(t *testing.T) {
+	fileSystem := New("/tmp")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := fileSystem.PutContext(ctx, "/canceled.txt", strings.NewReader("sample")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Put with canceled context should return context.Canceled, but got %v", err)
+	}
+
+	if _, err := fileSystem.ListContext(ctx, "/"); !errors.Is(err, context.Canceled) {
+		t.Errorf("List with canceled context should return context.Canceled, but got %v", err)
+	}
+}
+
+func TestListWithOptions(t *testing.T) {
+	fileSystem := New(t.TempDir())
+	for _, path := range []string{"/a.txt", "/b/1.txt", "/b/2.txt", "/c.txt"} {
+		if _, err := fileSystem.Put(path, strings.NewReader("sample")); err != nil {
+			t.Fatalf("No error should happen when save sample file, but got %v", err)
+		}
+	}
+
+	var paths []string
+	options := &oss.ListOptions{PageSize: 1, Delimiter: "/", StartAfter: "/a.txt"}
+	for {
+		result, err := fileSystem.ListWithOptions("/", options)
+		if err != nil {
+			t.Fatalf("No error should happen when list objects with options, but got %v", err)
+		}
+		if len(result.Objects)+len(result.CommonPrefixes) > 1 {
+			t.Errorf("Page should contain at most 1 item, but got %v and %v", result.Objects, result.CommonPrefixes)
+		}
+		for _, object := range result.Objects {
+			paths = append(paths, object.Path)
+		}
+		paths = append(paths, result.CommonPrefixes...)
+		if result.NextContinuationToken == "" {
+			break
+		}
+		options.ContinuationToken = result.NextContinuationToken
+	}
+
+	if strings.Join(paths, ",") != "/b/,/c.txt" {
+		t.Errorf("Should list /b/ and /c.txt, but got %v", paths)
+	}
+}
+
+func TestListWithOptionsPages(t *testing.T) {
+	fileSystem := New(t.TempDir())
+	paths := []string{"/a.txt", "/a/b.txt", "/a-1/c.txt", "/a/b/c/d.txt", "/b/1.txt", "/b/2.txt", "/b-c.txt", "/c/x-y/z.txt", "/c.txt", "/d/e/f/g.txt"}
+	for _, path := range paths {
+		if _, err := fileSystem.Put(path, strings.NewReader("sample")); err != nil {
+			t.Fatalf("No error should happen when save sample file, but got %v", err)
+		}
+	}
+	sort.Strings(paths)
+
+	// expected items listed from all objects sorted by path
+	expected := func(delimiter, startAfter string) []string {
+		var items []string
+		for _, path := range paths {
+			if path <= startAfter || delimiter != "" && strings.HasSuffix(startAfter, delimiter) && strings.HasPrefix(path, startAfter) {
+				continue
+			}
+			key := path
+			if i := strings.Index(path[1:], delimiter); delimiter != "" && i >= 0 {
+				key = path[:1+i+len(delimiter)]
+			}
+			if len(items) == 0 || items[len(items)-1] != key {
+				items = append(items, key)
+			}
+		}
+		return items
+	}
+
+	for _, delimiter := range []string{"", "/", "-"} {
+		for _, startAfter := range []string{"", "/a", "/a/", "/a/b.txt", "/b-", "/c/x-y/z.txt"} {
+			for _, pageSize := range []int{1, 2, 3, 100} {
+				var items []string
+				options := &oss.ListOptions{PageSize: pageSize, Delimiter: delimiter, StartAfter: startAfter}
+				for {
+					result, err := fileSystem.ListWithOptions("/", options)
+					if err != nil {
+						t.Fatalf("No error should happen when list objects with options, but got %v", err)
+					}
+					if len(result.Objects)+len(result.CommonPrefixes) > pageSize {
+						t.Errorf("Page should contain at most %v items, but got %v and %v", pageSize, result.Objects, result.CommonPrefixes)
+					}
+					for _, object := range result.Objects {
+						items = append(items, object.Path)
+					}
+					items = append(items, result.CommonPrefixes...)
+					if result.NextContinuationToken == "" {
+						break
+					}
+					options.ContinuationToken = result.NextContinuationToken
+				}
+
+				sort.Strings(items)
+				if want := expected(delimiter, startAfter); strings.Join(items, ",") != strings.Join(want, ",") {
+					t.Errorf("List with delimiter %q, start after %q and page size %v should get %v, but got %v", delimiter, startAfter, pageSize, want, items)
+				}
+			}
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	fileSystem := New(t.TempDir())
+	for _, path := range []string{"/a.txt", "/b/1.txt", "/b/2.txt", "/c.txt"} {
+		if _, err := fileSystem.Put(path, strings.NewReader("sample")); err != nil {
+			t.Fatalf("No error should happen when save sample file, but got %v", err)
+		}
+	}
+
+	var paths []string
+	err := oss.Walk(context.Background(), fileSystem, "/", func(object *oss.Object) error {
+		paths = append(paths, object.Path)
+		if len(paths) == 2 {
+			return oss.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("No error should happen when stop walking with SkipAll, but got %v", err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("Walk should stop after 2 objects, but got %v", paths)
+	}
+
+	stopErr := errors.New("stop")
+	if err := oss.Walk(context.Background(), fileSystem, "/", func(object *oss.Object) error { return stopErr }); err != stopErr {
+		t.Errorf("Walk should return the error of fn, but got %v", err)
+	}
+
+	// SkipAll stops walking without an error when the storage is walked directly
+	var walked int
+	if err := fileSystem.Walk("/", func(object *oss.Object) error { walked++; return oss.SkipAll }); err != nil || walked != 1 {
+		t.Errorf("Walk should stop without an error after SkipAll, but got %v after %v objects", err, walked)
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	base := t.TempDir()
+	fileSystem := New(base)
+	uploadID, err := fileSystem.InitiateUpload("/multipart.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when initiate upload, but got %v", err)
+	}
+	for number, content := range map[int]string{2: "world", 1: "hello "} {
+		if _, err := fileSystem.UploadPart("/multipart.txt", uploadID, number, strings.NewReader(content)); err != nil {
+			t.Fatalf("No error should happen when upload part %v, but got %v", number, err)
+		}
+	}
+
+	// resume the upload with another storage, as if the process restarted
+	fileSystem = New(base)
+	parts, err := fileSystem.ListParts("/multipart.txt", uploadID)
+	if err != nil {
+		t.Fatalf("No error should happen when list parts, but got %v", err)
+	}
+	if len(parts) != 2 || parts[0].Number != 1 || parts[1].Size != 5 {
+		t.Errorf("Uploaded parts should be listed in order, but got %v", parts)
+	}
+	if _, err := os.Stat(filepath.Join(base, ".oss-uploads", uploadID)); err != nil {
+		t.Errorf("Parts should be stored under the base, but got %v", err)
+	}
+	if _, err := fileSystem.ListParts("/other.txt", uploadID); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("Upload of another path should be not found, but got %v", err)
+	}
+	if objects, err := fileSystem.List("/"); err != nil || len(objects) != 0 {
+		t.Errorf("Parts shouldn't be listed, but got %v %v", objects, err)
+	}
+	if result, err := fileSystem.ListWithOptions("/", &oss.ListOptions{Delimiter: "/"}); err != nil || len(result.Objects) != 0 || len(result.CommonPrefixes) != 0 {
+		t.Errorf("Parts shouldn't be listed with options, but got %+v %v", result, err)
+	}
+
+	object, err := fileSystem.CompleteUpload("/multipart.txt", uploadID, nil)
+	if err != nil {
+		t.Fatalf("No error should happen when complete upload, but got %v", err)
+	}
+	if object.Size != 11 {
+		t.Errorf("Size of completed object should be 11, but got %v", object.Size)
+	}
+	file, err := fileSystem.Get("/multipart.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get completed object, but got %v", err)
+	}
+	defer file.Close()
+	if content, _ := ioutil.ReadAll(file); string(content) != "hello world" {
+		t.Errorf("Parts should be combined in order, but got %q", content)
+	}
+
+	if _, err := fileSystem.ListParts("/multipart.txt", uploadID); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("Completed upload should be not found, but got %v", err)
+	}
+}
+
+func TestAbortMultipartUpload(t *testing.T) {
+	fileSystem := New(t.TempDir())
+	uploadID, err := fileSystem.InitiateUpload("/aborted.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when initiate upload, but got %v", err)
+	}
+	if _, err := fileSystem.UploadPart("/aborted.txt", uploadID, 1, strings.NewReader("sample")); err != nil {
+		t.Fatalf("No error should happen when upload part, but got %v", err)
+	}
+	if err := fileSystem.AbortUpload("/aborted.txt", uploadID); err != nil {
+		t.Fatalf("No error should happen when abort upload, but got %v", err)
+	}
+	if _, err := fileSystem.UploadPart("/aborted.txt", uploadID, 2, strings.NewReader("sample")); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("Part shouldn't be uploaded to aborted upload, but got %v", err)
+	}
+	if _, err := fileSystem.Stat("/aborted.txt"); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("Aborted upload shouldn't create the object, but got %v", err)
+	}
+}
+
+func TestCopyOntoItself(t *testing.T) {
+	fileSystem := New(t.TempDir())
+	if _, err := fileSystem.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Fatalf("No error should happen when put file, but got %v", err)
+	}
+
+	for _, dst := range []string{"/sample.txt", "sample.txt", "/dir/../sample.txt"} {
+		if err := fileSystem.Copy("/sample.txt", dst); err != nil {
+			t.Errorf("No error should happen when copy file onto itself as %v, but got %v", dst, err)
+		}
+	}
+	if content, err := ioutil.ReadFile(fileSystem.GetFullPath("/sample.txt")); err != nil || string(content) != "sample" {
+		t.Errorf("File copied onto itself should be kept, but got %q, %v", content, err)
+	}
+	if err := fileSystem.Copy("/missing.txt", "/missing.txt"); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("Copy missing file onto itself should return ErrNotFound, but got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/casdoor/oss"
+)
+
+const (
+	// uploadsDir directory under the base storing parts of uploads until they're completed, it's skipped by List and Walk
+	uploadsDir = ".oss-uploads"
+	// targetFile file in the directory of an upload recording the path of the object
+	targetFile = "target"
+	// partExt extension of the files of uploaded parts, parts being written don't have it
+	partExt = ".part"
+)
+
+// InitiateUpload initiate a multipart upload, parts are stored in a directory under .oss-uploads of the base until the upload is completed
+func (fileSystem FileSystem) InitiateUpload(path string) (string, error) {
+	return fileSystem.InitiateUploadContext(context.Background(), path)
+}
+
+// InitiateUploadContext initiate a multipart upload, parts are stored in a directory under .oss-uploads of the base until the upload is completed
+func (fileSystem FileSystem) InitiateUploadContext(ctx context.Context, path string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id)
+	dir := fileSystem.uploadDir(uploadID)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", wrapError(err)
+	}
+	return uploadID, wrapError(ioutil.WriteFile(filepath.Join(dir, targetFile), []byte(path), os.ModePerm))
+}
+
+// UploadPart upload a part of a multipart upload
+func (fileSystem FileSystem) UploadPart(path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	return fileSystem.UploadPartContext(context.Background(), path, uploadID, number, reader)
+}
+
+// UploadPartContext upload a part of a multipart upload, the part is written to a temporary file
+// and renamed once it's complete, so a interrupted part isn't listed
+func (fileSystem FileSystem) UploadPartContext(ctx context.Context, path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	dir, err := fileSystem.existingUploadDir(uploadID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := ioutil.TempFile(dir, "tmp")
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer os.Remove(file.Name())
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), contextReader{ctx: ctx, reader: reader})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err := os.Rename(file.Name(), filepath.Join(dir, fmt.Sprintf("%05d%s", number, partExt))); err != nil {
+		return nil, wrapError(err)
+	}
+	return &oss.Part{Number: number, ETag: hex.EncodeToString(hash.Sum(nil)), Size: size}, nil
+}
+
+// ListParts list uploaded parts of a multipart upload, ETags of listed parts are empty
+func (fileSystem FileSystem) ListParts(path string, uploadID string) ([]*oss.Part, error) {
+	return fileSystem.ListPartsContext(context.Background(), path, uploadID)
+}
+
+// ListPartsContext list uploaded parts of a multipart upload, ETags of listed parts are empty
+func (fileSystem FileSystem) ListPartsContext(ctx context.Context, path string, uploadID string) ([]*oss.Part, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dir, err := fileSystem.existingUploadDir(uploadID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	var parts []*oss.Part
+	for _, info := range infos {
+		if !strings.HasSuffix(info.Name(), partExt) {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(info.Name(), partExt))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, &oss.Part{Number: number, Size: info.Size()})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+// CompleteUpload combine parts into the object, all uploaded parts are combined if parts is nil
+func (fileSystem FileSystem) CompleteUpload(path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	return fileSystem.CompleteUploadContext(context.Background(), path, uploadID, parts)
+}
+
+// CompleteUploadContext combine parts into the object, all uploaded parts are combined if parts is nil
+func (fileSystem FileSystem) CompleteUploadContext(ctx context.Context, path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	if parts == nil {
+		var err error
+		if parts, err = fileSystem.ListPartsContext(ctx, path, uploadID); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	dir, err := fileSystem.existingUploadDir(uploadID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		size    int64
+		readers []io.Reader
+	)
+	for _, part := range parts {
+		file, err := os.Open(filepath.Join(dir, fmt.Sprintf("%05d%s", part.Number, partExt)))
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		size += info.Size()
+		readers = append(readers, file)
+	}
+
+	object, err := fileSystem.PutContext(ctx, path, io.MultiReader(readers...))
+	if err != nil {
+		return nil, err
+	}
+	object.Size = size
+	return object, wrapError(os.RemoveAll(dir))
+}
+
+// AbortUpload abort a multipart upload and delete its uploaded parts
+func (fileSystem FileSystem) AbortUpload(path string, uploadID string) error {
+	return fileSystem.AbortUploadContext(context.Background(), path, uploadID)
+}
+
+// AbortUploadContext abort a multipart upload and delete its uploaded parts
+func (fileSystem FileSystem) AbortUploadContext(ctx context.Context, path string, uploadID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dir, err := fileSystem.existingUploadDir(uploadID, path)
+	if err != nil {
+		return err
+	}
+	return wrapError(os.RemoveAll(dir))
+}
+
+// uploadDir get the directory storing parts of an upload
+func (fileSystem FileSystem) uploadDir(uploadID string) string {
+	return filepath.Join(fileSystem.Base, uploadsDir, uploadID)
+}
+
+// existingUploadDir get the directory of an upload, ErrNotFound is returned if the upload doesn't exist
+// or it isn't an upload of path
+func (fileSystem FileSystem) existingUploadDir(uploadID string, path string) (string, error) {
+	if uploadID == "" || strings.ContainsAny(uploadID, `/\.`) {
+		return "", oss.NewError(oss.ErrNotFound, fmt.Errorf("invalid upload id %q", uploadID))
+	}
+
+	dir := fileSystem.uploadDir(uploadID)
+	target, err := ioutil.ReadFile(filepath.Join(dir, targetFile))
+	if err != nil {
+		return "", wrapError(err)
+	}
+	if fileSystem.GetFullPath(string(target)) != fileSystem.GetFullPath(path) {
+		return "", oss.NewError(oss.ErrNotFound, fmt.Errorf("upload %q isn't an upload of %v", uploadID, path))
+	}
+	return dir, nil
+}
@@ -16,23 +16,48 @@ package googlecloud
 
 import (
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
+	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/casdoor/oss"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+var (
+	_ oss.ContextStorage    = (*Client)(nil)
+	_ oss.Stater            = (*Client)(nil)
+	_ oss.OptionPutter      = (*Client)(nil)
+	_ oss.OptionLister      = (*Client)(nil)
+	_ oss.Walker            = (*Client)(nil)
+	_ oss.RangeGetter       = (*Client)(nil)
+	_ oss.Copier            = (*Client)(nil)
+	_ oss.UploadURLGetter   = (*Client)(nil)
+	_ oss.URLOptionGetter   = (*Client)(nil)
+	_ oss.MultipartUploader = (*Client)(nil)
+)
+
+// defaultPageSize page size of ListWithOptions if it isn't specified
+const defaultPageSize = 1000
+
 // Client Google Cloud Storage
 type Client struct {
 	Config       *Config
 	BucketHandle *storage.BucketHandle
+	httpClient   *http.Client
+	// apiEndpoint endpoint of resumable uploads, defaultAPIEndpoint if it's empty
+	apiEndpoint string
 }
 
 // Config Google Cloud Storage client config
@@ -40,6 +65,8 @@ type Config struct {
 	ServiceAccountJson string
 	Bucket             string
 	Endpoint           string
+	// PartSize size of parts of multipart uploads except the last one, which has to be a multiple of 256 KiB, 8 MiB by default
+	PartSize int64
 }
 
 // New initializes Google Cloud Storage
@@ -69,13 +96,19 @@ func New(config *Config) (*Client, error) {
 	client := &Client{
 		Config:       config,
 		BucketHandle: storageClient.Bucket(config.Bucket),
+		httpClient:   oauth2.NewClient(ctx, credentials.TokenSource),
 	}
 	return client, nil
 }
 
 // Get receives file with given path
 func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+	return client.GetContext(context.Background(), path)
+}
+
+// GetContext receives file with given path
+func (client Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamContext(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -101,20 +134,67 @@ func (client Client) Get(path string) (file *os.File, err error) {
 
 // GetStream gets file as stream
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	ctx := context.Background()
+	return client.GetStreamContext(context.Background(), path)
+}
+
+// GetStreamContext gets file as stream
+func (client Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
 	_, err := client.BucketHandle.Object(path).Attrs(ctx)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
-	return client.BucketHandle.Object(path).NewReader(ctx)
+	reader, err := client.BucketHandle.Object(path).NewReader(ctx)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return reader, nil
+}
+
+// GetRange gets a range of file as stream
+func (client Client) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return client.GetRangeContext(context.Background(), path, offset, length)
+}
+
+// GetRangeContext gets a range of file as stream
+func (client Client) GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	if length <= 0 {
+		length = -1
+	}
+
+	reader, err := client.BucketHandle.Object(path).NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return reader, nil
 }
 
 // Put stores a reader into given path
 func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error) {
-	ctx := context.Background()
+	return client.PutContext(context.Background(), urlPath, reader)
+}
+
+// PutContext stores a reader into given path
+func (client Client) PutContext(ctx context.Context, urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.PutWithOptionsContext(ctx, urlPath, reader, nil)
+}
 
+// PutWithOptions stores a reader into given path with options
+func (client Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return client.PutWithOptionsContext(context.Background(), urlPath, reader, options)
+}
+
+// PutWithOptionsContext stores a reader into given path with options
+func (client Client) PutWithOptionsContext(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
 	wc := client.BucketHandle.Object(urlPath).NewWriter(ctx)
+	if options != nil {
+		wc.ContentType = options.ContentType
+		wc.ContentDisposition = options.ContentDisposition
+		wc.CacheControl = options.CacheControl
+		wc.ContentEncoding = options.ContentEncoding
+		wc.Metadata = options.Metadata
+		wc.PredefinedACL = predefinedACL(options.ACL)
+	}
 
 	_, err := io.Copy(wc, reader)
 	if err != nil {
@@ -123,59 +203,271 @@ func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error)
 
 	err = wc.Close()
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	attrs, err := client.BucketHandle.Object(urlPath).Attrs(ctx)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	res := &oss.Object{
-		Path:             urlPath,
-		Name:             filepath.Base(urlPath),
+		Path:             "/" + attrs.Name,
+		Name:             filepath.Base(attrs.Name),
 		LastModified:     &attrs.Updated,
+		Size:             attrs.Size,
+		ContentType:      attrs.ContentType,
+		ETag:             attrs.Etag,
 		StorageInterface: client,
 	}
 	return res, nil
 }
 
+// predefinedACL converts canned ACL like public-read to the predefined ACL of google cloud like publicRead
+func predefinedACL(acl string) string {
+	parts := strings.Split(acl, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
 // Delete deletes file
 func (client Client) Delete(path string) error {
-	ctx := context.Background()
-	return client.BucketHandle.Object(path).Delete(ctx)
+	return client.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext deletes file
+func (client Client) DeleteContext(ctx context.Context, path string) error {
+	return wrapError(client.BucketHandle.Object(path).Delete(ctx))
+}
+
+// Copy copies object from src to dst on the server side
+func (client Client) Copy(src, dst string) error {
+	return client.CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copies object from src to dst on the server side
+func (client Client) CopyContext(ctx context.Context, src, dst string) error {
+	_, err := client.BucketHandle.Object(dst).CopierFrom(client.BucketHandle.Object(src)).Run(ctx)
+	return wrapError(err)
+}
+
+// Move moves object from src to dst, it's copied on the server side and then src is deleted
+func (client Client) Move(src, dst string) error {
+	return client.MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext moves object from src to dst, it's copied on the server side and then src is deleted
+func (client Client) MoveContext(ctx context.Context, src, dst string) error {
+	if err := client.CopyContext(ctx, src, dst); err != nil {
+		return err
+	}
+	return client.DeleteContext(ctx, src)
 }
 
 // List lists all objects under current path
 func (client Client) List(path string) ([]*oss.Object, error) {
+	return client.ListContext(context.Background(), path)
+}
+
+// ListContext lists all objects under current path
+func (client Client) ListContext(ctx context.Context, path string) ([]*oss.Object, error) {
 	var objects []*oss.Object
-	ctx := context.Background()
 
+	err := client.WalkContext(ctx, path, func(object *oss.Object) error {
+		objects = append(objects, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// Walk calls fn for every object under current path
+func (client Client) Walk(path string, fn oss.WalkFunc) error {
+	return client.WalkContext(context.Background(), path, fn)
+}
+
+// WalkContext calls fn for every object under current path, the iterator fetches objects page by page
+func (client Client) WalkContext(ctx context.Context, path string, fn oss.WalkFunc) error {
 	iter := client.BucketHandle.Objects(ctx, &storage.Query{Prefix: path})
 	for {
 		objAttrs, err := iter.Next()
 		if err == iterator.Done {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, err
+			return wrapError(err)
+		}
+
+		if err := fn(client.toObject(objAttrs)); err == oss.SkipAll {
+			return nil
+		} else if err != nil {
+			return err
 		}
+	}
+}
+
+// ListWithOptions lists a page of objects under current path
+func (client Client) ListWithOptions(path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	return client.ListWithOptionsContext(context.Background(), path, options)
+}
 
-		objects = append(objects, &oss.Object{
-			Path:             "/" + objAttrs.Name,
-			Name:             filepath.Base(objAttrs.Name),
-			LastModified:     &objAttrs.Updated,
-			Size:             objAttrs.Size,
-			StorageInterface: client,
-		})
+// ListWithOptionsContext lists a page of objects under current path
+func (client Client) ListWithOptionsContext(ctx context.Context, path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	if options == nil {
+		options = &oss.ListOptions{}
 	}
 
-	return objects, nil
+	query := &storage.Query{Prefix: path, Delimiter: options.Delimiter}
+	if options.StartAfter != "" {
+		// StartOffset is inclusive, so start from the smallest name after StartAfter
+		query.StartOffset = strings.TrimPrefix(options.StartAfter, "/") + "\x00"
+	}
+
+	pageSize := options.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	var attrsList []*storage.ObjectAttrs
+	pager := iterator.NewPager(client.BucketHandle.Objects(ctx, query), pageSize, options.ContinuationToken)
+	nextToken, err := pager.NextPage(&attrsList)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	result := &oss.ListResult{NextContinuationToken: nextToken}
+	for _, objAttrs := range attrsList {
+		if objAttrs.Prefix != "" {
+			result.CommonPrefixes = append(result.CommonPrefixes, "/"+objAttrs.Prefix)
+			continue
+		}
+		result.Objects = append(result.Objects, client.toObject(objAttrs))
+	}
+
+	return result, nil
+}
+
+func (client Client) toObject(objAttrs *storage.ObjectAttrs) *oss.Object {
+	return &oss.Object{
+		Path:             "/" + objAttrs.Name,
+		Name:             filepath.Base(objAttrs.Name),
+		LastModified:     &objAttrs.Updated,
+		Size:             objAttrs.Size,
+		StorageInterface: client,
+	}
+}
+
+// Stat gets object's metadata
+func (client Client) Stat(path string) (*oss.Object, error) {
+	return client.StatContext(context.Background(), path)
+}
+
+// StatContext gets object's metadata
+func (client Client) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	attrs, err := client.BucketHandle.Object(path).Attrs(ctx)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &oss.Object{
+		Path:             "/" + attrs.Name,
+		Name:             filepath.Base(attrs.Name),
+		LastModified:     &attrs.Updated,
+		Size:             attrs.Size,
+		ContentType:      attrs.ContentType,
+		ETag:             attrs.Etag,
+		StorageInterface: client,
+	}, nil
+}
+
+// GetUploadURL gets a V4 signed URL to upload file with PUT request
+func (client Client) GetUploadURL(path string, expiry time.Duration, contentType string) (string, error) {
+	return client.GetUploadURLContext(context.Background(), path, expiry, contentType)
+}
+
+// GetUploadURLContext gets a V4 signed URL to upload file with PUT request, the signer is detected from the credentials
+func (client Client) GetUploadURLContext(ctx context.Context, path string, expiry time.Duration, contentType string) (string, error) {
+	if expiry <= 0 {
+		expiry = oss.DefaultURLExpiry
+	}
+	return client.BucketHandle.SignedURL(path, &storage.SignedURLOptions{
+		Scheme:      storage.SigningSchemeV4,
+		Method:      http.MethodPut,
+		Expires:     time.Now().Add(expiry),
+		ContentType: contentType,
+	})
 }
 
 // GetURL get public accessible URL
 func (client Client) GetURL(path string) (url string, err error) {
-	return path, nil
+	return client.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get public accessible URL
+func (client Client) GetURLContext(ctx context.Context, path string) (url string, err error) {
+	return client.GetURLWithOptionsContext(ctx, path, nil)
+}
+
+// GetURLWithOptions gets public accessible URL with options
+func (client Client) GetURLWithOptions(path string, options *oss.URLOptions) (string, error) {
+	return client.GetURLWithOptionsContext(context.Background(), path, options)
+}
+
+// GetURLWithOptionsContext gets public accessible URL with options, a V4 signed URL is returned if any option is specified
+func (client Client) GetURLWithOptionsContext(ctx context.Context, path string, options *oss.URLOptions) (string, error) {
+	if options == nil || *options == (oss.URLOptions{}) {
+		return path, nil
+	}
+	if options.IP != "" || options.Referer != "" {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("google cloud storage doesn't support IP or referer restriction of signed URL"))
+	}
+
+	query := neturl.Values{}
+	if contentDisposition := options.ContentDisposition(path); contentDisposition != "" {
+		query.Set("response-content-disposition", contentDisposition)
+	}
+	if options.ResponseContentType != "" {
+		query.Set("response-content-type", options.ResponseContentType)
+	}
+
+	return client.BucketHandle.SignedURL(path, &storage.SignedURLOptions{
+		Scheme:          storage.SigningSchemeV4,
+		Method:          http.MethodGet,
+		Expires:         time.Now().Add(options.GetExpiry()),
+		QueryParameters: query,
+	})
+}
+
+// wrapError wraps google cloud error with oss errors, so it could be checked with errors.Is
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		return oss.NewError(oss.ErrNotFound, err)
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusNotFound:
+			return oss.NewError(oss.ErrNotFound, err)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return oss.NewError(oss.ErrPermission, err)
+		case http.StatusConflict:
+			return oss.NewError(oss.ErrAlreadyExists, err)
+		}
+	}
+
+	return err
 }
 
 func (client Client) GetEndpoint() string {
@@ -0,0 +1,385 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googlecloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casdoor/oss"
+	"github.com/casdoor/oss/internal/s3multipart"
+)
+
+const (
+	// defaultAPIEndpoint endpoint of the JSON API, resumable uploads are sent to its /upload path
+	defaultAPIEndpoint = "https://storage.googleapis.com"
+	// defaultPartSize size of parts of multipart uploads if Config.PartSize isn't specified
+	defaultPartSize = 8 << 20
+	// chunkSize granularity of resumable uploads, chunks except the last one are multiples of it
+	chunkSize = 256 << 10
+	// statusResumeIncomplete status of resumable upload responses before the object is finalized
+	statusResumeIncomplete = 308
+	// statusClientClosedRequest status of requests to a canceled resumable upload
+	statusClientClosedRequest = 499
+)
+
+// uploadedObject object resource returned when a resumable upload is finalized
+type uploadedObject struct {
+	Name        string
+	Size        string
+	ContentType string
+	ETag        string
+	Updated     time.Time
+}
+
+// uploadStatus progress of a resumable upload, object isn't nil if the upload is finalized
+type uploadStatus struct {
+	persisted int64
+	object    *uploadedObject
+}
+
+// InitiateUpload start a resumable upload session, the upload ID is the upload_id of the session URI
+func (client Client) InitiateUpload(path string) (string, error) {
+	return client.InitiateUploadContext(context.Background(), path)
+}
+
+// InitiateUploadContext start a resumable upload session, the upload ID is the upload_id of the session URI
+func (client Client) InitiateUploadContext(ctx context.Context, path string) (string, error) {
+	query := neturl.Values{}
+	query.Set("uploadType", "resumable")
+	query.Set("name", path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.uploadURL(query), nil)
+	if err != nil {
+		return "", err
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		req.Header.Set("X-Upload-Content-Type", contentType)
+	}
+
+	resp, err := client.doUploadRequest(req, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	location, err := neturl.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", err
+	}
+	uploadID := location.Query().Get("upload_id")
+	if uploadID == "" {
+		return "", fmt.Errorf("no upload_id in session URI %q", resp.Header.Get("Location"))
+	}
+	return uploadID, nil
+}
+
+// UploadPart upload a part of a resumable upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPart(path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	return client.UploadPartContext(context.Background(), path, uploadID, number, reader)
+}
+
+// UploadPartContext upload a part of a resumable upload, part n starts at (n-1)*Config.PartSize of the object.
+// Resumable uploads are sequential, so parts have to be uploaded in order, every part except the last one
+// has to be of Config.PartSize, and a smaller part finalizes the object as the last one.
+// Parts which have been persisted aren't sent again, ETags of parts are empty
+func (client Client) UploadPartContext(ctx context.Context, path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	partSize, err := client.partSize()
+	if err != nil {
+		return nil, err
+	}
+	body, size, err := s3multipart.PartBody(reader)
+	if err != nil {
+		return nil, err
+	}
+	if number < 1 || size > partSize {
+		return nil, fmt.Errorf("part %d of %d bytes is out of parts of %d bytes", number, size, partSize)
+	}
+	part := &oss.Part{Number: number, Size: size}
+	offset := int64(number-1) * partSize
+	last := size < partSize
+
+	status, err := client.uploadStatus(ctx, path, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if status.object != nil {
+		return nil, oss.NewError(oss.ErrAlreadyExists, fmt.Errorf("upload %q is finalized", uploadID))
+	}
+	if offset > status.persisted {
+		return nil, fmt.Errorf("part %d starts at %d bytes, but only %d bytes are uploaded, parts have to be uploaded in order", number, offset, status.persisted)
+	}
+	if !last && offset+size <= status.persisted {
+		return part, nil
+	}
+
+	start := status.persisted
+	if _, err := body.Seek(start-offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	total := "*"
+	if last {
+		total = strconv.FormatInt(offset+size, 10)
+	}
+	contentRange := fmt.Sprintf("bytes %d-%d/%s", start, offset+size-1, total)
+	if start == offset+size {
+		contentRange = "bytes */" + total
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, client.sessionURI(path, uploadID), ioutil.NopCloser(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = offset + size - start
+	req.Header.Set("Content-Range", contentRange)
+
+	expected := statusResumeIncomplete
+	if last {
+		expected = http.StatusOK
+	}
+	resp, err := client.doUploadRequest(req, expected)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if !last {
+		if persisted := persistedSize(resp.Header); persisted < offset+size {
+			return nil, fmt.Errorf("only %d bytes of part %d are persisted", persisted-offset, number)
+		}
+	}
+	return part, nil
+}
+
+// ListParts list uploaded parts of a resumable upload
+func (client Client) ListParts(path string, uploadID string) ([]*oss.Part, error) {
+	return client.ListPartsContext(context.Background(), path, uploadID)
+}
+
+// ListPartsContext list uploaded parts of a resumable upload, which are derived from the persisted size of the session.
+// A part which is persisted partially isn't listed, it's resumed from the persisted size when it's uploaded again
+func (client Client) ListPartsContext(ctx context.Context, path string, uploadID string) ([]*oss.Part, error) {
+	partSize, err := client.partSize()
+	if err != nil {
+		return nil, err
+	}
+	status, err := client.uploadStatus(ctx, path, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := status.persisted
+	if status.object != nil {
+		uploaded, _ = strconv.ParseInt(status.object.Size, 10, 64)
+	}
+	parts := []*oss.Part{}
+	for offset := int64(0); offset < uploaded; offset += partSize {
+		size := uploaded - offset
+		if size > partSize {
+			size = partSize
+		} else if size < partSize && status.object == nil {
+			break
+		}
+		parts = append(parts, &oss.Part{Number: len(parts) + 1, Size: size})
+	}
+	return parts, nil
+}
+
+// CompleteUpload finalize the object of a resumable upload, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUpload(path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	return client.CompleteUploadContext(context.Background(), path, uploadID, parts)
+}
+
+// CompleteUploadContext finalize the object of a resumable upload with the persisted bytes, all uploaded parts are combined
+// if parts is nil. Otherwise parts have to be all uploaded parts from 1 in order, as a resumable upload couldn't skip any of them
+func (client Client) CompleteUploadContext(ctx context.Context, path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	status, err := client.uploadStatus(ctx, path, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if parts != nil {
+		var size int64
+		for i, part := range s3multipart.SortedParts(parts) {
+			if part.Number != i+1 {
+				return nil, oss.NewError(oss.ErrUnsupported, fmt.Errorf("parts of a resumable upload couldn't skip part %d", i+1))
+			}
+			size += part.Size
+		}
+		uploaded := status.persisted
+		if status.object != nil {
+			uploaded, _ = strconv.ParseInt(status.object.Size, 10, 64)
+		}
+		if size != uploaded {
+			return nil, fmt.Errorf("parts have %d bytes, but %d bytes are uploaded", size, uploaded)
+		}
+	}
+
+	object := status.object
+	if object == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, client.sessionURI(path, uploadID), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", status.persisted))
+		resp, err := client.doUploadRequest(req, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+			return nil, err
+		}
+	}
+
+	size, _ := strconv.ParseInt(object.Size, 10, 64)
+	return &oss.Object{
+		Path:             "/" + object.Name,
+		Name:             filepath.Base(object.Name),
+		LastModified:     &object.Updated,
+		Size:             size,
+		ContentType:      object.ContentType,
+		ETag:             strings.Trim(object.ETag, `"`),
+		StorageInterface: client,
+	}, nil
+}
+
+// AbortUpload cancel a resumable upload and delete its uploaded bytes
+func (client Client) AbortUpload(path string, uploadID string) error {
+	return client.AbortUploadContext(context.Background(), path, uploadID)
+}
+
+// AbortUploadContext cancel a resumable upload and delete its uploaded bytes
+func (client Client) AbortUploadContext(ctx context.Context, path string, uploadID string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, client.sessionURI(path, uploadID), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.doUploadRequest(req, statusClientClosedRequest, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// uploadStatus query the persisted size of a resumable upload, or the object if it's finalized
+func (client Client) uploadStatus(ctx context.Context, path string, uploadID string) (*uploadStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, client.sessionURI(path, uploadID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Range", "bytes */*")
+
+	resp, err := client.doUploadRequest(req, statusResumeIncomplete, http.StatusOK, http.StatusCreated)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == statusResumeIncomplete {
+		return &uploadStatus{persisted: persistedSize(resp.Header)}, nil
+	}
+	var object uploadedObject
+	if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+		return nil, err
+	}
+	return &uploadStatus{object: &object}, nil
+}
+
+// persistedSize get the persisted size from the Range header of a resumable upload response, like bytes=0-262143
+func persistedSize(header http.Header) int64 {
+	_, last, ok := strings.Cut(header.Get("Range"), "-")
+	if !ok {
+		return 0
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return end + 1
+}
+
+// uploadURL get the URL of the resumable upload API of the bucket with query
+func (client Client) uploadURL(query neturl.Values) string {
+	endpoint := client.apiEndpoint
+	if endpoint == "" {
+		endpoint = defaultAPIEndpoint
+	}
+	return endpoint + "/upload/storage/v1/b/" + neturl.PathEscape(client.Config.Bucket) + "/o?" + query.Encode()
+}
+
+// sessionURI get the session URI of a resumable upload, the object name is used as it is like BucketHandle.Object
+func (client Client) sessionURI(path string, uploadID string) string {
+	query := neturl.Values{}
+	query.Set("uploadType", "resumable")
+	query.Set("name", path)
+	query.Set("upload_id", uploadID)
+	return client.uploadURL(query)
+}
+
+func (client Client) partSize() (int64, error) {
+	if client.Config.PartSize <= 0 {
+		return defaultPartSize, nil
+	}
+	if client.Config.PartSize%chunkSize != 0 {
+		return 0, fmt.Errorf("PartSize %d isn't a multiple of 256 KiB", client.Config.PartSize)
+	}
+	return client.Config.PartSize, nil
+}
+
+// doUploadRequest send req with the credentials of client, an error is returned unless the response has one of statuses,
+// 201 is accepted with 200 as both mean the object is finalized
+func (client Client) doUploadRequest(req *http.Request, statuses ...int) (*http.Response, error) {
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if resp.StatusCode == status || (status == http.StatusOK && resp.StatusCode == http.StatusCreated) {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+
+	d, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return nil, statusError(resp.StatusCode, errors.New(string(d)))
+}
+
+// statusError wrap err with oss errors according to the status code of the response,
+// canceled and expired uploads are not found
+func statusError(code int, err error) error {
+	switch code {
+	case http.StatusNotFound, http.StatusGone, statusClientClosedRequest:
+		return oss.NewError(oss.ErrNotFound, err)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return oss.NewError(oss.ErrPermission, err)
+	case http.StatusConflict:
+		return oss.NewError(oss.ErrAlreadyExists, err)
+	}
+	return err
+}
@@ -0,0 +1,241 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package googlecloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/casdoor/oss"
+)
+
+// fakeSession resumable upload session of fakeResumableServer
+type fakeSession struct {
+	name      string
+	data      []byte
+	finalized bool
+}
+
+// fakeResumableServer serves the resumable upload API of a bucket in memory
+type fakeResumableServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	sessions map[string]*fakeSession
+	requests []string
+}
+
+func newFakeResumableServer(t *testing.T) *fakeResumableServer {
+	fake := &fakeResumableServer{sessions: map[string]*fakeSession{}}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (fake *fakeResumableServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if r.URL.Path != "/upload/storage/v1/b/bucket/o" || r.URL.Query().Get("uploadType") != "resumable" {
+		http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+		return
+	}
+	fake.requests = append(fake.requests, r.Method+" "+r.Header.Get("Content-Range"))
+
+	if r.Method == http.MethodPost {
+		uploadID := strconv.Itoa(len(fake.sessions) + 1)
+		fake.sessions[uploadID] = &fakeSession{name: r.URL.Query().Get("name")}
+		w.Header().Set("Location", fake.URL+r.URL.RequestURI()+"&upload_id="+uploadID)
+		return
+	}
+
+	session, ok := fake.sessions[r.URL.Query().Get("upload_id")]
+	if !ok || session.name != r.URL.Query().Get("name") {
+		http.Error(w, "no such upload", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		delete(fake.sessions, r.URL.Query().Get("upload_id"))
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	case http.MethodPut:
+	default:
+		http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	contentRange := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+	byteRange, total, _ := strings.Cut(contentRange, "/")
+	if !session.finalized && byteRange != "*" {
+		first, _, _ := strings.Cut(byteRange, "-")
+		if start, _ := strconv.Atoi(first); start != len(session.data) {
+			http.Error(w, "unexpected start "+first, http.StatusBadRequest)
+			return
+		}
+		session.data = append(session.data, body...)
+	}
+	if total != "*" && strconv.Itoa(len(session.data)) == total {
+		session.finalized = true
+	}
+
+	if session.finalized {
+		json.NewEncoder(w).Encode(map[string]string{
+			"name":        session.name,
+			"size":        strconv.Itoa(len(session.data)),
+			"contentType": "application/zip",
+			"etag":        "etag",
+			"updated":     time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+	if len(session.data) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(session.data)-1))
+	}
+	w.WriteHeader(statusResumeIncomplete)
+}
+
+func newFakeResumableClient(t *testing.T) (*Client, *fakeResumableServer) {
+	fake := newFakeResumableServer(t)
+	client := &Client{
+		Config:      &Config{Bucket: "bucket", PartSize: chunkSize},
+		httpClient:  fake.Client(),
+		apiEndpoint: fake.URL,
+	}
+	return client, fake
+}
+
+func TestMultipartUploader(t *testing.T) {
+	client, fake := newFakeResumableClient(t)
+	data := bytes.Repeat([]byte("0123456789abcdef"), chunkSize/16*2+1)
+
+	uploadID, err := client.InitiateUpload("sample.zip")
+	if err != nil {
+		t.Fatalf("InitiateUpload: %v", err)
+	}
+
+	if _, err := client.UploadPart("sample.zip", uploadID, 2, bytes.NewReader(data[chunkSize:2*chunkSize])); err == nil {
+		t.Errorf("part 2 should be rejected before part 1")
+	}
+	if _, err := client.UploadPart("sample.zip", uploadID, 1, bytes.NewReader(data[:chunkSize])); err != nil {
+		t.Fatalf("UploadPart 1: %v", err)
+	}
+	// uploading part 1 again is a no-op as it's persisted
+	if _, err := client.UploadPart("sample.zip", uploadID, 1, bytes.NewReader(data[:chunkSize])); err != nil {
+		t.Fatalf("UploadPart 1 again: %v", err)
+	}
+	if _, err := client.UploadPart("sample.zip", uploadID, 2, bytes.NewReader(data[chunkSize:2*chunkSize])); err != nil {
+		t.Fatalf("UploadPart 2: %v", err)
+	}
+
+	parts, err := client.ListParts("sample.zip", uploadID)
+	if err != nil {
+		t.Fatalf("ListParts: %v", err)
+	}
+	if len(parts) != 2 || parts[0].Number != 1 || parts[1].Size != chunkSize {
+		t.Errorf("unexpected parts %+v %+v", parts[0], parts[len(parts)-1])
+	}
+
+	// the last part is smaller than PartSize and finalizes the object
+	last, err := client.UploadPart("sample.zip", uploadID, 3, bytes.NewReader(data[2*chunkSize:]))
+	if err != nil {
+		t.Fatalf("UploadPart 3: %v", err)
+	}
+	parts = append(parts, last)
+
+	object, err := client.CompleteUpload("sample.zip", uploadID, []*oss.Part{parts[2], parts[0], parts[1]})
+	if err != nil {
+		t.Fatalf("CompleteUpload: %v", err)
+	}
+	if object.Path != "/sample.zip" || object.Size != int64(len(data)) {
+		t.Errorf("unexpected object %+v", object)
+	}
+	if parts[2].Number != 3 {
+		t.Errorf("CompleteUpload shouldn't reorder the parts of callers")
+	}
+	if session := fake.sessions[uploadID]; !bytes.Equal(session.data, data) || !session.finalized {
+		t.Errorf("uploaded data doesn't match")
+	}
+
+	want := []string{"PUT bytes 0-262143/*", "PUT bytes 262144-524287/*", "PUT bytes 524288-524303/524304"}
+	var puts []string
+	for _, request := range fake.requests {
+		if request != "PUT bytes */*" && strings.HasPrefix(request, "PUT") {
+			puts = append(puts, request)
+		}
+	}
+	if strings.Join(puts, ",") != strings.Join(want, ",") {
+		t.Errorf("expected uploads %v, but got %v", want, puts)
+	}
+}
+
+func TestMultipartUploaderCompleteWithFullParts(t *testing.T) {
+	client, fake := newFakeResumableClient(t)
+	data := bytes.Repeat([]byte("a"), chunkSize)
+
+	uploadID, err := client.InitiateUpload("full.zip")
+	if err != nil {
+		t.Fatalf("InitiateUpload: %v", err)
+	}
+	if _, err := client.UploadPart("full.zip", uploadID, 1, bytes.NewReader(data)); err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if _, err := client.CompleteUpload("full.zip", uploadID, []*oss.Part{{Number: 2, Size: chunkSize}}); err == nil {
+		t.Errorf("parts skipping part 1 should be rejected")
+	}
+
+	object, err := client.CompleteUpload("full.zip", uploadID, nil)
+	if err != nil {
+		t.Fatalf("CompleteUpload: %v", err)
+	}
+	if object.Size != chunkSize || !fake.sessions[uploadID].finalized {
+		t.Errorf("object isn't finalized, got %+v", object)
+	}
+}
+
+func TestMultipartUploaderAbort(t *testing.T) {
+	client, _ := newFakeResumableClient(t)
+
+	uploadID, err := client.InitiateUpload("abort.zip")
+	if err != nil {
+		t.Fatalf("InitiateUpload: %v", err)
+	}
+	if err := client.AbortUpload("abort.zip", uploadID); err != nil {
+		t.Fatalf("AbortUpload: %v", err)
+	}
+	if _, err := client.ListParts("abort.zip", uploadID); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("expected not found after abort, got %v", err)
+	}
+}
+
+func TestMultipartUploaderPartSize(t *testing.T) {
+	client, _ := newFakeResumableClient(t)
+	client.Config.PartSize = chunkSize + 1
+
+	if _, err := client.UploadPart("sample.zip", "1", 1, bytes.NewReader([]byte("a"))); err == nil {
+		t.Errorf("PartSize which isn't a multiple of 256 KiB should be rejected")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3multipart XML shapes and helpers of S3 compatible multipart upload APIs, the shapes are shared by
+// storages sending the requests without an SDK like tencent, and the helpers by every multipart uploader
+package s3multipart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/casdoor/oss"
+)
+
+// InitiateResult response of `POST /<ObjectKey>?uploads`
+type InitiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+// Part a part in ListPartsResult and CompleteUpload
+type Part struct {
+	PartNumber int
+	ETag       string
+	Size       int64 `xml:",omitempty"`
+}
+
+// ListPartsResult response of `GET /<ObjectKey>?uploadId=UploadId`
+type ListPartsResult struct {
+	IsTruncated          bool
+	NextPartNumberMarker int
+	Parts                []Part `xml:"Part"`
+}
+
+// OSSParts convert parts of the result to oss parts, whose ETags aren't quoted
+func (result *ListPartsResult) OSSParts() []*oss.Part {
+	parts := make([]*oss.Part, 0, len(result.Parts))
+	for _, part := range result.Parts {
+		parts = append(parts, &oss.Part{Number: part.PartNumber, ETag: strings.Trim(part.ETag, `"`), Size: part.Size})
+	}
+	return parts
+}
+
+// CompleteUpload request body of `POST /<ObjectKey>?uploadId=UploadId`
+type CompleteUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []Part   `xml:"Part"`
+}
+
+// CompleteResult response of `POST /<ObjectKey>?uploadId=UploadId`
+type CompleteResult struct {
+	ETag string
+}
+
+// SortedParts get a copy of parts sorted by number, parts of callers are kept in their order
+func SortedParts(parts []*oss.Part) []*oss.Part {
+	sorted := append([]*oss.Part(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Number < sorted[j].Number })
+	return sorted
+}
+
+// CompleteBody marshal parts sorted by number into the request body of completing the upload,
+// the total size of parts is returned as well
+func CompleteBody(parts []*oss.Part) ([]byte, int64, error) {
+	var (
+		size     int64
+		complete CompleteUpload
+	)
+	for _, part := range SortedParts(parts) {
+		size += part.Size
+		complete.Parts = append(complete.Parts, Part{PartNumber: part.Number, ETag: `"` + part.ETag + `"`})
+	}
+	body, err := xml.Marshal(complete)
+	return body, size, err
+}
+
+// PartBody get the body of uploading a part from reader with its size, reader is read into memory if it isn't an io.ReadSeeker
+func PartBody(reader io.Reader) (io.ReadSeeker, int64, error) {
+	body, ok := reader.(io.ReadSeeker)
+	if !ok {
+		buffer, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, 0, err
+		}
+		body = bytes.NewReader(buffer)
+	}
+	size, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	return body, size, nil
+}
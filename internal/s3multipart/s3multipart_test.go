@@ -0,0 +1,41 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3multipart
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/casdoor/oss"
+)
+
+func TestCompleteBody(t *testing.T) {
+	parts := []*oss.Part{{Number: 2, ETag: "b", Size: 1}, {Number: 1, ETag: "a", Size: 5}}
+	body, size, err := CompleteBody(parts)
+	if err != nil {
+		t.Fatalf("No error should happen when marshal parts, but got %v", err)
+	}
+
+	var complete CompleteUpload
+	if err := xml.Unmarshal(body, &complete); err != nil {
+		t.Fatalf("No error should happen when unmarshal body, but got %v", err)
+	}
+	if size != 6 || len(complete.Parts) != 2 || complete.Parts[0].PartNumber != 1 || complete.Parts[1].ETag != `"b"` {
+		t.Errorf("Parts should be sorted by number with quoted ETags, but got %+v of %v bytes", complete.Parts, size)
+	}
+	if parts[0].Number != 2 {
+		t.Errorf("Parts of callers shouldn't be reordered")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss
+
+import (
+	"context"
+	"io"
+)
+
+// Part an uploaded part of a multipart upload, parts are numbered from 1
+type Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// MultipartUploader is implemented by storages which could upload an object in parts.
+// Upload IDs are kept by the storage, so an upload could be resumed with its ID after the process restarts,
+// ListParts tells which parts have been uploaded. The storage's minimum part size applies to every part except the last one
+type MultipartUploader interface {
+	InitiateUpload(path string) (uploadID string, err error)
+	InitiateUploadContext(ctx context.Context, path string) (uploadID string, err error)
+	UploadPart(path string, uploadID string, number int, reader io.Reader) (*Part, error)
+	UploadPartContext(ctx context.Context, path string, uploadID string, number int, reader io.Reader) (*Part, error)
+	ListParts(path string, uploadID string) ([]*Part, error)
+	ListPartsContext(ctx context.Context, path string, uploadID string) ([]*Part, error)
+	// CompleteUpload combine parts into the object, all uploaded parts are combined if parts is nil
+	CompleteUpload(path string, uploadID string, parts []*Part) (*Object, error)
+	CompleteUploadContext(ctx context.Context, path string, uploadID string, parts []*Part) (*Object, error)
+	AbortUpload(path string, uploadID string) error
+	AbortUploadContext(ctx context.Context, path string, uploadID string) error
+}
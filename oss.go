@@ -15,8 +15,13 @@
 package oss
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -31,12 +36,215 @@ type StorageInterface interface {
 	GetEndpoint() string
 }
 
+// Stater is implemented by storages which could get object's metadata without downloading it
+type Stater interface {
+	Stat(path string) (*Object, error)
+	StatContext(ctx context.Context, path string) (*Object, error)
+}
+
+// Stat get object's metadata, ErrUnsupported is returned if the storage isn't a Stater
+func Stat(storage StorageInterface, path string) (*Object, error) {
+	if stater, ok := storage.(Stater); ok {
+		return stater.Stat(path)
+	}
+	return nil, ErrUnsupported
+}
+
+// PutOptions options of uploading an object, empty fields fall back to the storage's default.
+// ErrUnsupported is returned if a field is set which the storage couldn't store, instead of ignoring it
+type PutOptions struct {
+	ContentType        string
+	ContentDisposition string
+	CacheControl       string
+	ContentEncoding    string
+	Metadata           map[string]string
+	// ACL canned ACL of the object, e.g. private, public-read
+	ACL string
+}
+
+// CheckSupported return ErrUnsupported if a field is set other than supported ones, which are names of
+// the fields like "ContentType". storage is the name of the storage used in the error
+func (options *PutOptions) CheckSupported(storage string, supported ...string) error {
+	if options == nil {
+		return nil
+	}
+
+	fields := []struct {
+		name string
+		set  bool
+	}{
+		{"ContentType", options.ContentType != ""},
+		{"ContentDisposition", options.ContentDisposition != ""},
+		{"CacheControl", options.CacheControl != ""},
+		{"ContentEncoding", options.ContentEncoding != ""},
+		{"Metadata", len(options.Metadata) > 0},
+		{"ACL", options.ACL != ""},
+	}
+	for _, field := range fields {
+		if !field.set {
+			continue
+		}
+		var ok bool
+		for _, name := range supported {
+			ok = ok || name == field.name
+		}
+		if !ok {
+			return NewError(ErrUnsupported, fmt.Errorf("%s doesn't support %s of PutOptions", storage, field.name))
+		}
+	}
+	return nil
+}
+
+// OptionPutter is implemented by storages which could upload an object with PutOptions
+type OptionPutter interface {
+	PutWithOptions(path string, reader io.Reader, options *PutOptions) (*Object, error)
+	PutWithOptionsContext(ctx context.Context, path string, reader io.Reader, options *PutOptions) (*Object, error)
+}
+
+// ListOptions options of listing objects under a path
+type ListOptions struct {
+	// ContinuationToken the NextContinuationToken of the last page, empty to list from the beginning
+	ContinuationToken string
+	// PageSize max count of objects and common prefixes in a page, the storage's default is used if zero
+	PageSize int
+	// Delimiter objects whose path contains it after the listed path are grouped into CommonPrefixes, e.g. "/"
+	Delimiter string
+	// StartAfter only list objects whose path is after it
+	StartAfter string
+}
+
+// ListResult a page of listed objects
+type ListResult struct {
+	Objects []*Object
+	// CommonPrefixes paths ending with the delimiter, like directories
+	CommonPrefixes []string
+	// NextContinuationToken token to get the next page, empty if there are no more pages
+	NextContinuationToken string
+}
+
+// OptionLister is implemented by storages which could list objects page by page with ListOptions
+type OptionLister interface {
+	ListWithOptions(path string, options *ListOptions) (*ListResult, error)
+	ListWithOptionsContext(ctx context.Context, path string, options *ListOptions) (*ListResult, error)
+}
+
+// RangeGetter is implemented by storages which could read a range of object's content,
+// a non-positive length reads to the end of the object
+type RangeGetter interface {
+	GetRange(path string, offset, length int64) (io.ReadCloser, error)
+	GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error)
+}
+
+// HTTPRange format the value of HTTP Range header, which reads length bytes from offset,
+// a non-positive length reads to the end of the object
+func HTTPRange(offset, length int64) string {
+	if length <= 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// RangeBody get the body of a response to a request with the Range header HTTPRange(offset, length).
+// If the server ignored the header and responded the whole object with 200, like some proxies and CDNs,
+// the bytes before offset are discarded and the rest is limited to length, so that the range is still right
+func RangeBody(resp *http.Response, offset, length int64) (io.ReadCloser, error) {
+	if resp.StatusCode == http.StatusPartialContent {
+		return resp.Body, nil
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, resp.Body, offset); err != nil {
+		resp.Body.Close()
+		if err == io.EOF {
+			return nil, fmt.Errorf("oss: range starts at %d beyond the end of the object", offset)
+		}
+		return nil, err
+	}
+	if length <= 0 {
+		return resp.Body, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, length), resp.Body}, nil
+}
+
+// UploadURLGetter is implemented by storages which could presign an URL, with which clients like browsers
+// upload the object directly with a PUT request before it expires. If contentType isn't empty,
+// the request has to be sent with the same Content-Type. DefaultURLExpiry is used if expiry isn't positive.
+// Storages which require more headers in the request implement UploadHeadersGetter as well,
+// whose headers have to be sent with the URL
+type UploadURLGetter interface {
+	GetUploadURL(path string, expiry time.Duration, contentType string) (string, error)
+	GetUploadURLContext(ctx context.Context, path string, expiry time.Duration, contentType string) (string, error)
+}
+
+// UploadHeadersGetter is implemented by storages whose upload URLs from UploadURLGetter are only accepted
+// with certain headers, like x-ms-blob-type of Azure. The headers include Content-Type if contentType isn't empty
+type UploadHeadersGetter interface {
+	GetUploadHeaders(contentType string) http.Header
+}
+
+// GetUploadHeaders get the headers which have to be sent with the upload URL of storage,
+// which is only Content-Type unless storage implements UploadHeadersGetter
+func GetUploadHeaders(storage StorageInterface, contentType string) http.Header {
+	if getter, ok := storage.(UploadHeadersGetter); ok {
+		return getter.GetUploadHeaders(contentType)
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return header
+}
+
+// DefaultURLExpiry lifetime of signed URLs if URLOptions.Expiry isn't specified
+const DefaultURLExpiry = time.Hour
+
+// URLOptions options of getting an URL of an object. Restrictions which the storage couldn't apply,
+// IP and Referer, cause ErrUnsupported, so that the URL is never less restricted than requested
+type URLOptions struct {
+	// Expiry lifetime of the URL if it's signed, DefaultURLExpiry is used if zero
+	Expiry time.Duration
+	// Download force browsers to download the object as an attachment with its name
+	Download bool
+	// ResponseContentType override the Content-Type of the response
+	ResponseContentType string
+	// IP only allow requests from the IP, or an IP range like 10.0.0.1-10.0.0.255
+	IP string
+	// Referer only allow requests with the Referer
+	Referer string
+}
+
+// GetExpiry get the lifetime of signed URL, which falls back to DefaultURLExpiry
+func (options *URLOptions) GetExpiry() time.Duration {
+	if options == nil || options.Expiry <= 0 {
+		return DefaultURLExpiry
+	}
+	return options.Expiry
+}
+
+// ContentDisposition get the Content-Disposition of the response for the object with path, empty if it's not a download
+func (options *URLOptions) ContentDisposition(path string) string {
+	if options == nil || !options.Download {
+		return ""
+	}
+	return fmt.Sprintf("attachment; filename=%q", filepath.Base(path))
+}
+
+// URLOptionGetter is implemented by storages which could get an URL of an object with URLOptions
+type URLOptionGetter interface {
+	GetURLWithOptions(path string, options *URLOptions) (string, error)
+	GetURLWithOptionsContext(ctx context.Context, path string, options *URLOptions) (string, error)
+}
+
 // Object content object
 type Object struct {
 	Path             string
 	Name             string
 	LastModified     *time.Time
 	Size             int64
+	ContentType      string
+	ETag             string
 	StorageInterface StorageInterface
 }
 
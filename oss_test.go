@@ -0,0 +1,96 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casdoor/oss"
+)
+
+func TestHTTPRange(t *testing.T) {
+	cases := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 10, "bytes=0-9"},
+		{5, 1, "bytes=5-5"},
+		{5, 0, "bytes=5-"},
+		{5, -1, "bytes=5-"},
+	}
+
+	for _, c := range cases {
+		if got := oss.HTTPRange(c.offset, c.length); got != c.want {
+			t.Errorf("HTTPRange(%v, %v) should be %v, but got %v", c.offset, c.length, c.want, got)
+		}
+	}
+}
+
+func TestURLOptions(t *testing.T) {
+	var options *oss.URLOptions
+	if options.GetExpiry() != oss.DefaultURLExpiry {
+		t.Errorf("Expiry of nil options should be %v, but got %v", oss.DefaultURLExpiry, options.GetExpiry())
+	}
+	if options.ContentDisposition("/dir/sample.txt") != "" {
+		t.Errorf("Content disposition of nil options should be empty")
+	}
+
+	options = &oss.URLOptions{Expiry: time.Minute, Download: true}
+	if options.GetExpiry() != time.Minute {
+		t.Errorf("Expiry should be %v, but got %v", time.Minute, options.GetExpiry())
+	}
+	if got := options.ContentDisposition("/dir/sample.txt"); got != `attachment; filename="sample.txt"` {
+		t.Errorf("Content disposition should be attachment with file name, but got %v", got)
+	}
+}
+
+func TestPutOptionsCheckSupported(t *testing.T) {
+	var options *oss.PutOptions
+	if err := options.CheckSupported("storage"); err != nil {
+		t.Errorf("Nil options should be supported, but got %v", err)
+	}
+
+	options = &oss.PutOptions{ContentType: "text/plain", Metadata: map[string]string{"key": "value"}}
+	if err := options.CheckSupported("storage", "ContentType", "Metadata"); err != nil {
+		t.Errorf("Supported options shouldn't cause errors, but got %v", err)
+	}
+	if err := options.CheckSupported("storage", "ContentType"); !errors.Is(err, oss.ErrUnsupported) || !strings.Contains(err.Error(), "Metadata") {
+		t.Errorf("Error should be ErrUnsupported naming Metadata, but got %v", err)
+	}
+	if err := (&oss.PutOptions{CacheControl: "no-cache"}).CheckSupported("storage", "ContentType"); !errors.Is(err, oss.ErrUnsupported) {
+		t.Errorf("Error should be ErrUnsupported for CacheControl, but got %v", err)
+	}
+}
+
+func TestRangeBody(t *testing.T) {
+	partial := &http.Response{StatusCode: http.StatusPartialContent, Body: ioutil.NopCloser(strings.NewReader("234"))}
+	if body, err := oss.RangeBody(partial, 2, 3); err != nil {
+		t.Errorf("No error should happen for partial content, but got %v", err)
+	} else if content, _ := ioutil.ReadAll(body); string(content) != "234" {
+		t.Errorf("Partial content should be returned as it is, but got %q", content)
+	}
+
+	whole := &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("0123456789"))}
+	if body, err := oss.RangeBody(whole, 2, 3); err != nil {
+		t.Errorf("No error should happen for the whole object, but got %v", err)
+	} else if content, _ := ioutil.ReadAll(body); string(content) != "234" {
+		t.Errorf("Range should be cut from the whole object, but got %q", content)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qiniu
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/casdoor/oss"
+	"github.com/qiniu/go-sdk/v7/storage"
+)
+
+type listPartsRet struct {
+	PartNumberMarker int `json:"partNumberMarker"`
+	Parts            []struct {
+		Size       int64  `json:"size"`
+		Etag       string `json:"etag"`
+		PartNumber int    `json:"partNumber"`
+	} `json:"parts"`
+}
+
+// InitiateUpload initiate a multipart upload with qiniu's resumable upload v2
+func (client Client) InitiateUpload(urlPath string) (string, error) {
+	return client.InitiateUploadContext(context.Background(), urlPath)
+}
+
+// InitiateUploadContext initiate a multipart upload with qiniu's resumable upload v2
+func (client Client) InitiateUploadContext(ctx context.Context, urlPath string) (string, error) {
+	upHost, err := client.upHost()
+	if err != nil {
+		return "", err
+	}
+
+	key := storageKey(urlPath)
+	var ret storage.InitPartsRet
+	err = client.resumeUploader().InitParts(ctx, client.uploadToken(key), upHost, client.Config.Bucket, key, true, &ret)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	return ret.UploadID, nil
+}
+
+// UploadPart upload a part of a multipart upload, the part is read into memory to get its md5
+func (client Client) UploadPart(urlPath string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	return client.UploadPartContext(context.Background(), urlPath, uploadID, number, reader)
+}
+
+// UploadPartContext upload a part of a multipart upload, the part is read into memory to get its md5
+func (client Client) UploadPartContext(ctx context.Context, urlPath string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	upHost, err := client.upHost()
+	if err != nil {
+		return nil, err
+	}
+
+	buffer, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(buffer)
+
+	key := storageKey(urlPath)
+	var ret storage.UploadPartsRet
+	err = client.resumeUploader().UploadParts(ctx, client.uploadToken(key), upHost, client.Config.Bucket, key, true,
+		uploadID, int64(number), hex.EncodeToString(sum[:]), &ret, bytes.NewReader(buffer), len(buffer))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	return &oss.Part{Number: number, ETag: ret.Etag, Size: int64(len(buffer))}, nil
+}
+
+// ListParts list uploaded parts of a multipart upload
+func (client Client) ListParts(urlPath string, uploadID string) ([]*oss.Part, error) {
+	return client.ListPartsContext(context.Background(), urlPath, uploadID)
+}
+
+// ListPartsContext list uploaded parts of a multipart upload
+func (client Client) ListPartsContext(ctx context.Context, urlPath string, uploadID string) ([]*oss.Part, error) {
+	uploadURL, err := client.uploadURL(urlPath, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		parts  []*oss.Part
+		marker int
+	)
+	for {
+		query := url.Values{}
+		if marker > 0 {
+			query.Set("part-number-marker", strconv.Itoa(marker))
+		}
+
+		var ret listPartsRet
+		err := client.bucketManager.Client.Call(ctx, &ret, "GET", uploadURL+"?"+query.Encode(), client.uploadHeader(storageKey(urlPath)))
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		for _, part := range ret.Parts {
+			parts = append(parts, &oss.Part{Number: part.PartNumber, ETag: part.Etag, Size: part.Size})
+		}
+
+		if ret.PartNumberMarker == 0 || len(ret.Parts) == 0 {
+			return parts, nil
+		}
+		marker = ret.PartNumberMarker
+	}
+}
+
+// CompleteUpload combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUpload(urlPath string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	return client.CompleteUploadContext(context.Background(), urlPath, uploadID, parts)
+}
+
+// CompleteUploadContext combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUploadContext(ctx context.Context, urlPath string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	upHost, err := client.upHost()
+	if err != nil {
+		return nil, err
+	}
+	if parts == nil {
+		if parts, err = client.ListPartsContext(ctx, urlPath, uploadID); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+
+	key := storageKey(urlPath)
+	extra := &storage.RputV2Extra{MimeType: mime.TypeByExtension(path.Ext(key))}
+	var size int64
+	for _, part := range parts {
+		size += part.Size
+		extra.Progresses = append(extra.Progresses, storage.UploadPartInfo{Etag: part.ETag, PartNumber: int64(part.Number)})
+	}
+
+	var ret storage.PutRet
+	err = client.resumeUploader().CompleteParts(ctx, client.uploadToken(key), upHost, &ret, client.Config.Bucket, key, true, uploadID, extra)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	now := time.Now()
+	return &oss.Object{
+		Path:             ret.Key,
+		Name:             filepath.Base(key),
+		LastModified:     &now,
+		Size:             size,
+		ContentType:      extra.MimeType,
+		ETag:             ret.Hash,
+		StorageInterface: client,
+	}, nil
+}
+
+// AbortUpload abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUpload(urlPath string, uploadID string) error {
+	return client.AbortUploadContext(context.Background(), urlPath, uploadID)
+}
+
+// AbortUploadContext abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUploadContext(ctx context.Context, urlPath string, uploadID string) error {
+	uploadURL, err := client.uploadURL(urlPath, uploadID)
+	if err != nil {
+		return err
+	}
+	return wrapError(client.bucketManager.Client.Call(ctx, nil, "DELETE", uploadURL, client.uploadHeader(storageKey(urlPath))))
+}
+
+func (client Client) resumeUploader() *storage.ResumeUploaderV2 {
+	return storage.NewResumeUploaderV2(&client.storageCfg)
+}
+
+func (client Client) upHost() (string, error) {
+	return client.resumeUploader().UpHost(client.Config.AccessID, client.Config.Bucket)
+}
+
+// uploadToken get an upload token which is allowed to overwrite key
+func (client Client) uploadToken(key string) string {
+	putPolicy := storage.PutPolicy{
+		Scope: fmt.Sprintf("%s:%s", client.Config.Bucket, key),
+	}
+	return putPolicy.UploadToken(client.mac)
+}
+
+func (client Client) uploadHeader(key string) http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "UpToken "+client.uploadToken(key))
+	return header
+}
+
+// uploadURL get the URL of a resumable upload v2, which is used to list its parts and abort it
+func (client Client) uploadURL(urlPath string, uploadID string) (string, error) {
+	upHost, err := client.upHost()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/buckets/%s/objects/%s/uploads/%s", upHost, client.Config.Bucket,
+		base64.URLEncoding.EncodeToString([]byte(storageKey(urlPath))), uploadID), nil
+}
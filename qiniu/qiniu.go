@@ -17,6 +17,7 @@ package qiniu
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -27,14 +28,32 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/casdoor/oss"
+	"github.com/qiniu/go-sdk/v7/auth"
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
+	qiniuclient "github.com/qiniu/go-sdk/v7/client"
 	"github.com/qiniu/go-sdk/v7/storage"
 )
 
+var (
+	_ oss.ContextStorage    = (*Client)(nil)
+	_ oss.Stater            = (*Client)(nil)
+	_ oss.OptionPutter      = (*Client)(nil)
+	_ oss.OptionLister      = (*Client)(nil)
+	_ oss.RangeGetter       = (*Client)(nil)
+	_ oss.Copier            = (*Client)(nil)
+	_ oss.UploadURLGetter   = (*Client)(nil)
+	_ oss.URLOptionGetter   = (*Client)(nil)
+	_ oss.MultipartUploader = (*Client)(nil)
+)
+
+// maxListLimit max count of items qiniu returns in a page
+const maxListLimit = 1000
+
 // Client Qiniu storage
 type Client struct {
 	Config        *Config
@@ -94,7 +113,15 @@ func (client Client) SetPutPolicy(putPolicy *storage.PutPolicy) {
 
 // Get receive file with given path
 func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+	return client.GetContext(context.Background(), path)
+}
+
+// GetContext receive file with given path
+func (client Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 
 	if file, err = ioutil.TempFile(os.TempDir(), "qiniu"); err == nil {
 		defer readCloser.Close()
@@ -107,22 +134,84 @@ func (client Client) Get(path string) (file *os.File, err error) {
 
 // GetStream get file as stream
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	purl, err := client.GetURL(path)
+	return client.GetStreamContext(context.Background(), path)
+}
+
+// GetStreamContext get file as stream
+func (client Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	resp, err := client.getStream(ctx, path, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// GetRange get a range of file as stream
+func (client Client) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return client.GetRangeContext(context.Background(), path, offset, length)
+}
+
+// GetRangeContext get a range of file as stream
+func (client Client) GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := client.getStream(ctx, path, oss.HTTPRange(offset, length))
+	if err != nil {
+		return nil, err
+	}
+	return oss.RangeBody(resp, offset, length)
+}
+
+// getStream download file from its URL, the whole file is requested if rangeHeader is empty
+func (client Client) getStream(ctx context.Context, path string, rangeHeader string) (*http.Response, error) {
+	purl, err := client.GetURLContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", purl, nil)
 	if err != nil {
 		return nil, err
 	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
 
 	var res *http.Response
-	res, err = http.Get(purl)
-	if err == nil && res.StatusCode != http.StatusOK {
-		err = fmt.Errorf("file %s not found", path)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		res.Body.Close()
+		return nil, statusError(res.StatusCode, fmt.Errorf("get file %s fail, status code: %d", path, res.StatusCode))
 	}
 
-	return res.Body, err
+	return res, nil
 }
 
 // Put store a reader into given path
 func (client Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err error) {
+	return client.PutContext(context.Background(), urlPath, reader)
+}
+
+// PutContext store a reader into given path
+func (client Client) PutContext(ctx context.Context, urlPath string, reader io.Reader) (r *oss.Object, err error) {
+	return client.PutWithOptionsContext(ctx, urlPath, reader, nil)
+}
+
+// PutWithOptions store a reader into given path with options
+func (client Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (r *oss.Object, err error) {
+	return client.PutWithOptionsContext(context.Background(), urlPath, reader, options)
+}
+
+// PutWithOptionsContext store a reader into given path with options, only ContentType and Metadata are supported by qiniu
+func (client Client) PutWithOptionsContext(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (r *oss.Object, err error) {
+	if options == nil {
+		options = &oss.PutOptions{}
+	}
+	if err := options.CheckSupported("qiniu", "ContentType", "Metadata"); err != nil {
+		return nil, err
+	}
+
 	if seeker, ok := reader.(io.ReadSeeker); ok {
 		seeker.Seek(0, 0)
 	}
@@ -134,7 +223,10 @@ func (client Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err e
 		return
 	}
 
-	fileType := mime.TypeByExtension(path.Ext(urlPath))
+	fileType := options.ContentType
+	if fileType == "" {
+		fileType = mime.TypeByExtension(path.Ext(urlPath))
+	}
 	if fileType == "" {
 		fileType = http.DetectContentType(buffer)
 	}
@@ -154,10 +246,15 @@ func (client Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err e
 	dataLen := int64(len(buffer))
 
 	putExtra := storage.PutExtra{
-		Params: map[string]string{},
+		Params:   map[string]string{},
+		MimeType: fileType,
 	}
-	err = formUploader.Put(context.Background(), &ret, upToken, urlPath, bytes.NewReader(buffer), dataLen, &putExtra)
+	for key, value := range options.Metadata {
+		putExtra.Params["x-qn-meta-"+key] = value
+	}
+	err = formUploader.Put(ctx, &ret, upToken, urlPath, bytes.NewReader(buffer), dataLen, &putExtra)
 	if err != nil {
+		err = wrapError(err)
 		return
 	}
 
@@ -166,41 +263,202 @@ func (client Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err e
 		Path:             ret.Key,
 		Name:             filepath.Base(urlPath),
 		LastModified:     &now,
+		Size:             dataLen,
+		ContentType:      fileType,
+		ETag:             ret.Hash,
 		StorageInterface: client,
 	}, err
 }
 
 // Delete delete file
 func (client Client) Delete(path string) error {
-	return client.bucketManager.Delete(client.Config.Bucket, storageKey(path))
+	return client.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext delete file
+func (client Client) DeleteContext(ctx context.Context, path string) error {
+	return wrapError(client.rsCall(ctx, nil, storage.URIDelete(client.Config.Bucket, storageKey(path))))
+}
+
+// Copy copy object from src to dst on the server side, dst is overwritten if it exists
+func (client Client) Copy(src, dst string) error {
+	return client.CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copy object from src to dst on the server side, dst is overwritten if it exists
+func (client Client) CopyContext(ctx context.Context, src, dst string) error {
+	bucket := client.Config.Bucket
+	return wrapError(client.rsCall(ctx, nil, storage.URICopy(bucket, storageKey(src), bucket, storageKey(dst), true)))
+}
+
+// Move move object from src to dst on the server side, dst is overwritten if it exists
+func (client Client) Move(src, dst string) error {
+	return client.MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext move object from src to dst on the server side, dst is overwritten if it exists
+func (client Client) MoveContext(ctx context.Context, src, dst string) error {
+	bucket := client.Config.Bucket
+	return wrapError(client.rsCall(ctx, nil, storage.URIMove(bucket, storageKey(src), bucket, storageKey(dst), true)))
 }
 
 // List list all objects under current path
 func (client Client) List(path string) (objects []*oss.Object, err error) {
-	var prefix = storageKey(path)
-	var listItems []storage.ListItem
-	listItems, _, _, _, err = client.bucketManager.ListFiles(
-		client.Config.Bucket,
-		prefix,
-		"",
-		"",
-		100,
-	)
+	return client.ListContext(context.Background(), path)
+}
+
+// ListContext list all objects under current path
+func (client Client) ListContext(ctx context.Context, path string) (objects []*oss.Object, err error) {
+	options := &oss.ListOptions{}
+
+	for {
+		var result *oss.ListResult
+		if result, err = client.ListWithOptionsContext(ctx, path, options); err != nil {
+			return
+		}
+		objects = append(objects, result.Objects...)
+
+		if result.NextContinuationToken == "" {
+			return
+		}
+		options.ContinuationToken = result.NextContinuationToken
+	}
+}
+
+// ListWithOptions list a page of objects under current path, StartAfter isn't supported by qiniu
+func (client Client) ListWithOptions(path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	return client.ListWithOptionsContext(context.Background(), path, options)
+}
 
+// ListWithOptionsContext list a page of objects under current path, StartAfter isn't supported by qiniu
+func (client Client) ListWithOptionsContext(ctx context.Context, path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	if options == nil {
+		options = &oss.ListOptions{}
+	}
+	if options.StartAfter != "" {
+		return nil, oss.NewError(oss.ErrUnsupported, errors.New("qiniu doesn't support listing start after a key"))
+	}
+
+	limit := options.PageSize
+	if limit <= 0 || limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	ret, err := client.listFiles(ctx, storageKey(path), options.Delimiter, options.ContinuationToken, limit)
 	if err != nil {
-		return
+		return nil, wrapError(err)
 	}
 
-	for _, content := range listItems {
-		t := time.Unix(content.PutTime, 0)
-		objects = append(objects, &oss.Object{
+	result := &oss.ListResult{NextContinuationToken: ret.Marker}
+	for _, content := range ret.Items {
+		t := putTime(content.PutTime)
+		result.Objects = append(result.Objects, &oss.Object{
 			Path:             "/" + storageKey(content.Key),
 			Name:             filepath.Base(content.Key),
 			LastModified:     &t,
+			Size:             content.Fsize,
 			StorageInterface: client,
 		})
 	}
+	for _, commonPrefix := range ret.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, "/"+storageKey(commonPrefix))
+	}
+
+	return result, nil
+}
+
+// Stat get object's metadata
+func (client Client) Stat(path string) (*oss.Object, error) {
+	return client.StatContext(context.Background(), path)
+}
+
+// StatContext get object's metadata
+func (client Client) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	key := storageKey(path)
+
+	var info storage.FileInfo
+	if err := client.rsCall(ctx, &info, storage.URIStat(client.Config.Bucket, key)); err != nil {
+		return nil, wrapError(err)
+	}
+	lastModified := putTime(info.PutTime)
+
+	return &oss.Object{
+		Path:             "/" + key,
+		Name:             filepath.Base(key),
+		LastModified:     &lastModified,
+		Size:             info.Fsize,
+		ContentType:      info.MimeType,
+		ETag:             info.Hash,
+		StorageInterface: client,
+	}, nil
+}
+
+// putTime convert qiniu's put time, which is in units of 100 nanoseconds, to time
+func putTime(t int64) time.Time {
+	return time.Unix(0, t*100)
+}
+
+// wrapError wrap qiniu error with oss errors, so it could be checked with errors.Is
+func wrapError(err error) error {
+	var errorInfo *qiniuclient.ErrorInfo
+	if errors.As(err, &errorInfo) {
+		return statusError(errorInfo.Code, err)
+	}
+	return err
+}
+
+// statusError wrap err according to the status code returned by qiniu,
+// 612 and 614 are qiniu's codes of "no such file or directory" and "file exists"
+func statusError(code int, err error) error {
+	switch code {
+	case http.StatusNotFound, 612:
+		return oss.NewError(oss.ErrNotFound, err)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return oss.NewError(oss.ErrPermission, err)
+	case 614:
+		return oss.NewError(oss.ErrAlreadyExists, err)
+	}
+	return err
+}
+
+// rsCall call the rs api of current bucket, BucketManager of the sdk always uses context.Background()
+func (client Client) rsCall(ctx context.Context, ret interface{}, uri string) error {
+	reqHost, err := client.bucketManager.RsReqHost(client.Config.Bucket)
+	if err != nil {
+		return err
+	}
+	return client.bucketManager.Client.CredentialedCall(ctx, client.mac, auth.TokenQiniu, ret, "POST", reqHost+uri, nil)
+}
+
+type listFilesRet struct {
+	Marker         string             `json:"marker"`
+	Items          []storage.ListItem `json:"items"`
+	CommonPrefixes []string           `json:"commonPrefixes"`
+}
 
+// listFiles call the rsf list api of current bucket
+func (client Client) listFiles(ctx context.Context, prefix, delimiter, marker string, limit int) (ret listFilesRet, err error) {
+	reqHost, err := client.bucketManager.RsfReqHost(client.Config.Bucket)
+	if err != nil {
+		return
+	}
+
+	query := url.Values{}
+	query.Set("bucket", client.Config.Bucket)
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	err = client.bucketManager.Client.CredentialedCall(ctx, client.mac, auth.TokenQiniu, &ret, "POST", reqHost+"/list?"+query.Encode(), nil)
 	return
 }
 
@@ -220,20 +478,67 @@ func storageKey(urlPath string) string {
 	return strings.TrimPrefix(urlPath, "/")
 }
 
+// GetUploadURL get an upload token built from PutPolicy, qiniu uploads with tokens instead of signed URLs,
+// the token restricts the key of the object and its mime type if contentType isn't empty
+func (client Client) GetUploadURL(path string, expiry time.Duration, contentType string) (string, error) {
+	return client.GetUploadURLContext(context.Background(), path, expiry, contentType)
+}
+
+// GetUploadURLContext get an upload token built from PutPolicy, qiniu uploads with tokens instead of signed URLs,
+// the token restricts the key of the object and its mime type if contentType isn't empty
+func (client Client) GetUploadURLContext(ctx context.Context, path string, expiry time.Duration, contentType string) (string, error) {
+	if expiry <= 0 {
+		expiry = oss.DefaultURLExpiry
+	}
+	putPolicy := storage.PutPolicy{
+		Scope:     fmt.Sprintf("%s:%s", client.Config.Bucket, storageKey(path)),
+		Expires:   uint64(expiry.Seconds()),
+		MimeLimit: contentType,
+	}
+	return putPolicy.UploadToken(client.mac), nil
+}
+
 // GetURL get public accessible URL
 func (client Client) GetURL(path string) (url string, err error) {
+	return client.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get public accessible URL
+func (client Client) GetURLContext(ctx context.Context, path string) (url string, err error) {
+	return client.GetURLWithOptionsContext(ctx, path, nil)
+}
+
+// GetURLWithOptions get public accessible URL with options
+func (client Client) GetURLWithOptions(path string, options *oss.URLOptions) (string, error) {
+	return client.GetURLWithOptionsContext(context.Background(), path, options)
+}
+
+// GetURLWithOptionsContext get public accessible URL with options, downloads are forced with the attname parameter.
+// qiniu couldn't override the content type of the response, so options.ResponseContentType causes oss.ErrUnsupported
+func (client Client) GetURLWithOptionsContext(ctx context.Context, path string, options *oss.URLOptions) (string, error) {
+	if options == nil {
+		options = &oss.URLOptions{}
+	}
+	if options.IP != "" || options.Referer != "" {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("qiniu doesn't support IP or referer restriction of private URL"))
+	}
+	if options.ResponseContentType != "" {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("qiniu doesn't support overriding the content type of the response"))
+	}
+
 	if len(path) == 0 {
-		return
+		return "", nil
 	}
 	key := storageKey(path)
+	if options.Download {
+		// the key isn't escaped by MakePublicURL and MakePrivateURL, so the query is appended to it
+		key += "?attname=" + url.QueryEscape(filepath.Base(key))
+	}
 
 	if client.Config.PrivateURL {
-		deadline := time.Now().Add(time.Second * 3600).Unix()
-		url = storage.MakePrivateURL(client.mac, client.Config.Endpoint, key, deadline)
-		return
+		deadline := time.Now().Add(options.GetExpiry()).Unix()
+		return storage.MakePrivateURL(client.mac, client.Config.Endpoint, key, deadline), nil
 	}
 
-	url = storage.MakePublicURL(client.GetEndpoint(), key)
-
-	return
+	return storage.MakePublicURL(client.GetEndpoint(), key), nil
 }
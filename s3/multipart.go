@@ -0,0 +1,181 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"io"
+	"mime"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/casdoor/oss"
+	"github.com/casdoor/oss/internal/s3multipart"
+)
+
+// InitiateUpload initiate a multipart upload
+func (client Client) InitiateUpload(urlPath string) (string, error) {
+	return client.InitiateUploadContext(context.Background(), urlPath)
+}
+
+// InitiateUploadContext initiate a multipart upload
+func (client Client) InitiateUploadContext(ctx context.Context, urlPath string) (string, error) {
+	key := client.objectKey(urlPath)
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(client.Config.Bucket),
+		Key:    aws.String(key),
+		ACL:    aws.String(client.Config.ACL),
+	}
+	if fileType := mime.TypeByExtension(path.Ext(key)); fileType != "" {
+		input.ContentType = aws.String(fileType)
+	}
+	if client.Config.CacheControl != "" {
+		input.CacheControl = aws.String(client.Config.CacheControl)
+	}
+
+	output, err := client.S3.CreateMultipartUploadWithContext(ctx, input)
+	if err != nil {
+		return "", wrapError(err)
+	}
+	return aws.StringValue(output.UploadId), nil
+}
+
+// UploadPart upload a part of a multipart upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPart(urlPath string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	return client.UploadPartContext(context.Background(), urlPath, uploadID, number, reader)
+}
+
+// UploadPartContext upload a part of a multipart upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPartContext(ctx context.Context, urlPath string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	body, size, err := s3multipart.PartBody(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := client.S3.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(client.Config.Bucket),
+		Key:        aws.String(client.objectKey(urlPath)),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(int64(number)),
+		Body:       body,
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &oss.Part{Number: number, ETag: strings.Trim(aws.StringValue(output.ETag), `"`), Size: size}, nil
+}
+
+// ListParts list uploaded parts of a multipart upload
+func (client Client) ListParts(urlPath string, uploadID string) ([]*oss.Part, error) {
+	return client.ListPartsContext(context.Background(), urlPath, uploadID)
+}
+
+// ListPartsContext list uploaded parts of a multipart upload
+func (client Client) ListPartsContext(ctx context.Context, urlPath string, uploadID string) ([]*oss.Part, error) {
+	var parts []*oss.Part
+
+	err := client.S3.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+		Bucket:   aws.String(client.Config.Bucket),
+		Key:      aws.String(client.objectKey(urlPath)),
+		UploadId: aws.String(uploadID),
+	}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+		for _, part := range page.Parts {
+			parts = append(parts, &oss.Part{
+				Number: int(aws.Int64Value(part.PartNumber)),
+				ETag:   strings.Trim(aws.StringValue(part.ETag), `"`),
+				Size:   aws.Int64Value(part.Size),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return parts, nil
+}
+
+// CompleteUpload combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUpload(urlPath string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	return client.CompleteUploadContext(context.Background(), urlPath, uploadID, parts)
+}
+
+// CompleteUploadContext combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUploadContext(ctx context.Context, urlPath string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	if parts == nil {
+		var err error
+		if parts, err = client.ListPartsContext(ctx, urlPath, uploadID); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		size           int64
+		completedParts []*s3.CompletedPart
+	)
+	for _, part := range s3multipart.SortedParts(parts) {
+		size += part.Size
+		completedParts = append(completedParts, &s3.CompletedPart{
+			PartNumber: aws.Int64(int64(part.Number)),
+			ETag:       aws.String(`"` + part.ETag + `"`),
+		})
+	}
+
+	key := client.objectKey(urlPath)
+	output, err := client.S3.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(client.Config.Bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	now := time.Now()
+	return &oss.Object{
+		Path:             client.ToRelativePath(key),
+		Name:             filepath.Base(key),
+		LastModified:     &now,
+		Size:             size,
+		ETag:             strings.Trim(aws.StringValue(output.ETag), `"`),
+		StorageInterface: client,
+	}, nil
+}
+
+// AbortUpload abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUpload(urlPath string, uploadID string) error {
+	return client.AbortUploadContext(context.Background(), urlPath, uploadID)
+}
+
+// AbortUploadContext abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUploadContext(ctx context.Context, urlPath string, uploadID string) error {
+	_, err := client.S3.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(client.Config.Bucket),
+		Key:      aws.String(client.objectKey(urlPath)),
+		UploadId: aws.String(uploadID),
+	})
+	return wrapError(err)
+}
+
+// objectKey get the key of object with path, which has no leading slash
+func (client Client) objectKey(urlPath string) string {
+	return strings.TrimPrefix(client.ToRelativePath(urlPath), "/")
+}
@@ -15,7 +15,9 @@
 package s3
 
 import (
-	"bytes"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -30,15 +32,30 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/casdoor/oss"
 )
 
+var (
+	_ oss.ContextStorage    = (*Client)(nil)
+	_ oss.Stater            = (*Client)(nil)
+	_ oss.OptionPutter      = (*Client)(nil)
+	_ oss.OptionLister      = (*Client)(nil)
+	_ oss.Walker            = (*Client)(nil)
+	_ oss.RangeGetter       = (*Client)(nil)
+	_ oss.Copier            = (*Client)(nil)
+	_ oss.UploadURLGetter   = (*Client)(nil)
+	_ oss.URLOptionGetter   = (*Client)(nil)
+	_ oss.MultipartUploader = (*Client)(nil)
+)
+
 // Client S3 storage
 type Client struct {
 	*s3.S3
@@ -57,6 +74,10 @@ type Config struct {
 	S3Endpoint       string
 	S3ForcePathStyle bool
 	CacheControl     string
+	// PartSize size of each part of multipart uploads, s3manager.DefaultUploadPartSize (5 MB) if zero
+	PartSize int64
+	// Concurrency count of parts uploaded concurrently, s3manager.DefaultUploadConcurrency if zero
+	Concurrency int
 
 	Session *session.Session
 
@@ -131,7 +152,12 @@ func New(config *Config) *Client {
 
 // Get receive file with given path
 func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+	return client.GetContext(context.Background(), path)
+}
+
+// GetContext receive file with given path
+func (client Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamContext(ctx, path)
 
 	ext := filepath.Ext(path)
 	pattern := fmt.Sprintf("s3*%s", ext)
@@ -149,62 +175,169 @@ func (client Client) Get(path string) (file *os.File, err error) {
 
 // GetStream get file as stream
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	getResponse, err := client.S3.GetObject(&s3.GetObjectInput{
+	return client.GetStreamContext(context.Background(), path)
+}
+
+// GetStreamContext get file as stream
+func (client Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	getResponse, err := client.S3.GetObjectWithContext(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(client.Config.Bucket),
 		Key:    aws.String(client.ToRelativePath(path)),
 	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return getResponse.Body, nil
+}
 
-	return getResponse.Body, err
+// GetRange get a range of file as stream
+func (client Client) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return client.GetRangeContext(context.Background(), path, offset, length)
+}
+
+// GetRangeContext get a range of file as stream
+func (client Client) GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	getResponse, err := client.S3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(client.Config.Bucket),
+		Key:    aws.String(client.ToRelativePath(path)),
+		Range:  aws.String(oss.HTTPRange(offset, length)),
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return getResponse.Body, nil
 }
 
 // Put store a reader into given path
 func (client Client) Put(urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.PutContext(context.Background(), urlPath, reader)
+}
+
+// PutContext store a reader into given path
+func (client Client) PutContext(ctx context.Context, urlPath string, reader io.Reader) (*oss.Object, error) {
+	return client.PutWithOptionsContext(ctx, urlPath, reader, nil)
+}
+
+// PutWithOptions store a reader into given path with options
+func (client Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return client.PutWithOptionsContext(context.Background(), urlPath, reader, options)
+}
+
+// PutWithOptionsContext store a reader into given path with options
+func (client Client) PutWithOptionsContext(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if options == nil {
+		options = &oss.PutOptions{}
+	}
+
 	if seeker, ok := reader.(io.ReadSeeker); ok {
 		seeker.Seek(0, 0)
 	}
 
 	urlPath = client.ToRelativePath(urlPath)
-	buffer, err := ioutil.ReadAll(reader)
+	// only the first chunk is read to sniff the content type, the rest is streamed by the uploader
+	bufferedReader := bufio.NewReaderSize(reader, sniffLen)
+	counter := &countingReader{Reader: bufferedReader}
 
-	fileType := mime.TypeByExtension(path.Ext(urlPath))
+	fileType := options.ContentType
+	if fileType == "" {
+		fileType = mime.TypeByExtension(path.Ext(urlPath))
+	}
 	if fileType == "" {
-		fileType = http.DetectContentType(buffer)
+		head, err := bufferedReader.Peek(sniffLen)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		fileType = http.DetectContentType(head)
+	}
+
+	acl := client.Config.ACL
+	if options.ACL != "" {
+		acl = options.ACL
 	}
 
-	params := &s3.PutObjectInput{
-		Bucket:        aws.String(client.Config.Bucket), // required
-		Key:           aws.String(urlPath),              // required
-		ACL:           aws.String(client.Config.ACL),
-		Body:          bytes.NewReader(buffer),
-		ContentLength: aws.Int64(int64(len(buffer))),
-		ContentType:   aws.String(fileType),
+	params := &s3manager.UploadInput{
+		Bucket:      aws.String(client.Config.Bucket), // required
+		Key:         aws.String(urlPath),              // required
+		ACL:         aws.String(acl),
+		Body:        counter,
+		ContentType: aws.String(fileType),
 	}
-	if client.Config.CacheControl != "" {
+	if options.CacheControl != "" {
+		params.CacheControl = aws.String(options.CacheControl)
+	} else if client.Config.CacheControl != "" {
 		params.CacheControl = aws.String(client.Config.CacheControl)
 	}
+	if options.ContentDisposition != "" {
+		params.ContentDisposition = aws.String(options.ContentDisposition)
+	}
+	if options.ContentEncoding != "" {
+		params.ContentEncoding = aws.String(options.ContentEncoding)
+	}
+	if len(options.Metadata) > 0 {
+		params.Metadata = aws.StringMap(options.Metadata)
+	}
 
-	_, err = client.S3.PutObject(params)
+	// the uploader sends a single PutObject for small files, and a multipart upload which is aborted on error otherwise
+	uploader := s3manager.NewUploaderWithClient(client.S3, func(uploader *s3manager.Uploader) {
+		if client.Config.PartSize > 0 {
+			uploader.PartSize = client.Config.PartSize
+		}
+		if client.Config.Concurrency > 0 {
+			uploader.Concurrency = client.Config.Concurrency
+		}
+		uploader.LeavePartsOnError = false
+	})
+	_, err := uploader.UploadWithContext(ctx, params)
 
 	now := time.Now()
 	return &oss.Object{
 		Path:             urlPath,
 		Name:             filepath.Base(urlPath),
 		LastModified:     &now,
+		Size:             counter.n,
+		ContentType:      fileType,
 		StorageInterface: client,
-	}, err
+	}, wrapError(err)
+}
+
+// sniffLen count of bytes used by http.DetectContentType
+const sniffLen = 512
+
+// countingReader counts bytes read from Reader
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (reader *countingReader) Read(p []byte) (int, error) {
+	n, err := reader.Reader.Read(p)
+	reader.n += int64(n)
+	return n, err
 }
 
 // Delete delete file
 func (client Client) Delete(path string) error {
-	_, err := client.S3.DeleteObject(&s3.DeleteObjectInput{
+	return client.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext delete file
+func (client Client) DeleteContext(ctx context.Context, path string) error {
+	_, err := client.S3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(client.Config.Bucket),
 		Key:    aws.String(client.ToRelativePath(path)),
 	})
-	return err
+	return wrapError(err)
 }
 
 // DeleteObjects delete files in bulk
 func (client Client) DeleteObjects(paths []string) (err error) {
+	return client.DeleteObjectsContext(context.Background(), paths)
+}
+
+// DeleteObjectsContext delete files in bulk
+func (client Client) DeleteObjectsContext(ctx context.Context, paths []string) (err error) {
 	var objs []*s3.ObjectIdentifier
 	for _, v := range paths {
 		var obj s3.ObjectIdentifier
@@ -218,39 +351,221 @@ func (client Client) DeleteObjects(paths []string) (err error) {
 		},
 	}
 
-	_, err = client.S3.DeleteObjects(input)
+	_, err = client.S3.DeleteObjectsWithContext(ctx, input)
 	if err != nil {
-		return
+		return wrapError(err)
 	}
 	return
 }
 
+// Copy copy object from src to dst on the server side
+func (client Client) Copy(src, dst string) error {
+	return client.CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext copy object from src to dst on the server side
+func (client Client) CopyContext(ctx context.Context, src, dst string) error {
+	copySource := &url.URL{Path: client.Config.Bucket + client.ToRelativePath(src)}
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(client.Config.Bucket),
+		CopySource: aws.String(copySource.EscapedPath()),
+		Key:        aws.String(strings.TrimPrefix(client.ToRelativePath(dst), "/")),
+	}
+	if client.Config.ACL != "" {
+		input.ACL = aws.String(client.Config.ACL)
+	}
+
+	_, err := client.S3.CopyObjectWithContext(ctx, input)
+	return wrapError(err)
+}
+
+// Move move object from src to dst, it's copied on the server side and then src is deleted
+func (client Client) Move(src, dst string) error {
+	return client.MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext move object from src to dst, it's copied on the server side and then src is deleted
+func (client Client) MoveContext(ctx context.Context, src, dst string) error {
+	if err := client.CopyContext(ctx, src, dst); err != nil {
+		return err
+	}
+	return client.DeleteContext(ctx, src)
+}
+
 // List list all objects under current path
 func (client Client) List(path string) ([]*oss.Object, error) {
+	return client.ListContext(context.Background(), path)
+}
+
+// ListContext list all objects under current path
+func (client Client) ListContext(ctx context.Context, path string) ([]*oss.Object, error) {
 	var objects []*oss.Object
+	options := &oss.ListOptions{}
+
+	for {
+		result, err := client.ListWithOptionsContext(ctx, path, options)
+		if err != nil {
+			return objects, err
+		}
+		objects = append(objects, result.Objects...)
+
+		if result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		options.ContinuationToken = result.NextContinuationToken
+	}
+}
+
+// ListWithOptions list a page of objects under current path
+func (client Client) ListWithOptions(path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	return client.ListWithOptionsContext(context.Background(), path, options)
+}
+
+// ListWithOptionsContext list a page of objects under current path
+func (client Client) ListWithOptionsContext(ctx context.Context, path string, options *oss.ListOptions) (*oss.ListResult, error) {
 	var prefix string
 
 	if path != "" {
 		prefix = strings.Trim(path, "/") + "/"
 	}
+	if options == nil {
+		options = &oss.ListOptions{}
+	}
 
-	listObjectsResponse, err := client.S3.ListObjectsV2(&s3.ListObjectsV2Input{
+	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(client.Config.Bucket),
 		Prefix: aws.String(prefix),
+	}
+	if options.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(options.ContinuationToken)
+	}
+	if options.PageSize > 0 {
+		input.MaxKeys = aws.Int64(int64(options.PageSize))
+	}
+	if options.Delimiter != "" {
+		input.Delimiter = aws.String(options.Delimiter)
+	}
+	if options.StartAfter != "" {
+		input.StartAfter = aws.String(strings.TrimPrefix(client.ToRelativePath(options.StartAfter), "/"))
+	}
+
+	listObjectsResponse, err := client.S3.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	result := &oss.ListResult{}
+	for _, content := range listObjectsResponse.Contents {
+		result.Objects = append(result.Objects, client.toObject(content))
+	}
+	for _, commonPrefix := range listObjectsResponse.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, client.ToRelativePath(aws.StringValue(commonPrefix.Prefix)))
+	}
+	if aws.BoolValue(listObjectsResponse.IsTruncated) {
+		result.NextContinuationToken = aws.StringValue(listObjectsResponse.NextContinuationToken)
+	}
+
+	return result, nil
+}
+
+// Walk call fn for every object under current path
+func (client Client) Walk(path string, fn oss.WalkFunc) error {
+	return client.WalkContext(context.Background(), path, fn)
+}
+
+// WalkContext call fn for every object under current path, objects are listed page by page
+func (client Client) WalkContext(ctx context.Context, path string, fn oss.WalkFunc) error {
+	var prefix string
+	var fnErr error
+
+	if path != "" {
+		prefix = strings.Trim(path, "/") + "/"
+	}
+
+	err := client.S3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(client.Config.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, content := range page.Contents {
+			if fnErr = fn(client.toObject(content)); fnErr != nil {
+				return false
+			}
+		}
+		return true
 	})
+	if err != nil {
+		return wrapError(err)
+	}
+	if fnErr == oss.SkipAll {
+		return nil
+	}
+	return fnErr
+}
 
+func (client Client) toObject(content *s3.Object) *oss.Object {
+	return &oss.Object{
+		Path:             client.ToRelativePath(*content.Key),
+		Name:             filepath.Base(*content.Key),
+		LastModified:     content.LastModified,
+		Size:             aws.Int64Value(content.Size),
+		StorageInterface: client,
+	}
+}
+
+// Stat get object's metadata
+func (client Client) Stat(path string) (*oss.Object, error) {
+	return client.StatContext(context.Background(), path)
+}
+
+// StatContext get object's metadata
+func (client Client) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	key := client.ToRelativePath(path)
+	headResponse, err := client.S3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(client.Config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &oss.Object{
+		Path:             key,
+		Name:             filepath.Base(key),
+		LastModified:     headResponse.LastModified,
+		Size:             aws.Int64Value(headResponse.ContentLength),
+		ContentType:      aws.StringValue(headResponse.ContentType),
+		ETag:             strings.Trim(aws.StringValue(headResponse.ETag), `"`),
+		StorageInterface: client,
+	}, nil
+}
+
+// wrapError wrap aws error with oss errors, so it could be checked with errors.Is
+func wrapError(err error) error {
 	if err == nil {
-		for _, content := range listObjectsResponse.Contents {
-			objects = append(objects, &oss.Object{
-				Path:             client.ToRelativePath(*content.Key),
-				Name:             filepath.Base(*content.Key),
-				LastModified:     content.LastModified,
-				StorageInterface: client,
-			})
+		return nil
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket, "NotFound":
+			return oss.NewError(oss.ErrNotFound, err)
+		case "AccessDenied", "Forbidden":
+			return oss.NewError(oss.ErrPermission, err)
 		}
 	}
 
-	return objects, err
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		switch requestFailure.StatusCode() {
+		case http.StatusNotFound:
+			return oss.NewError(oss.ErrNotFound, err)
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return oss.NewError(oss.ErrPermission, err)
+		}
+	}
+
+	return err
 }
 
 // GetEndpoint get endpoint, FileSystem's endpoint is /
@@ -269,6 +584,28 @@ func (client Client) GetEndpoint() string {
 
 var urlRegexp = regexp.MustCompile(`(https?:)?//((\w+).)+(\w+)/`)
 
+// GetUploadURL get a presigned URL to upload file with PUT request
+func (client Client) GetUploadURL(path string, expiry time.Duration, contentType string) (string, error) {
+	return client.GetUploadURLContext(context.Background(), path, expiry, contentType)
+}
+
+// GetUploadURLContext get a presigned URL to upload file with PUT request
+func (client Client) GetUploadURLContext(ctx context.Context, path string, expiry time.Duration, contentType string) (string, error) {
+	if expiry <= 0 {
+		expiry = oss.DefaultURLExpiry
+	}
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(client.Config.Bucket),
+		Key:    aws.String(client.ToRelativePath(path)),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	putRequest, _ := client.S3.PutObjectRequest(input)
+	return putRequest.Presign(expiry)
+}
+
 // ToRelativePath process path to relative path
 func (client Client) ToRelativePath(urlPath string) string {
 	if urlRegexp.MatchString(urlPath) {
@@ -288,14 +625,49 @@ func (client Client) ToRelativePath(urlPath string) string {
 
 // GetURL get public accessible URL
 func (client Client) GetURL(path string) (url string, err error) {
-	if client.Endpoint == "" {
-		if client.Config.ACL == s3.BucketCannedACLPrivate || client.Config.ACL == s3.BucketCannedACLAuthenticatedRead {
-			getResponse, _ := client.S3.GetObjectRequest(&s3.GetObjectInput{
+	return client.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get public accessible URL
+func (client Client) GetURLContext(ctx context.Context, path string) (url string, err error) {
+	return client.GetURLWithOptionsContext(ctx, path, nil)
+}
+
+// GetURLWithOptions get public accessible URL with options
+func (client Client) GetURLWithOptions(path string, options *oss.URLOptions) (string, error) {
+	return client.GetURLWithOptionsContext(context.Background(), path, options)
+}
+
+// GetURLWithOptionsContext get public accessible URL with options, it's presigned if the ACL is private or the response is overridden.
+// URLs of Config.Endpoint, like a CDN, aren't presigned, so options other than empty ones cause oss.ErrUnsupported with it
+func (client Client) GetURLWithOptionsContext(ctx context.Context, path string, options *oss.URLOptions) (string, error) {
+	if options == nil {
+		options = &oss.URLOptions{}
+	}
+	if options.IP != "" || options.Referer != "" {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("s3 doesn't support IP or referer restriction of presigned URL"))
+	}
+	if client.Config.Endpoint != "" && (options.Expiry != 0 || options.Download || options.ResponseContentType != "") {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("s3 doesn't presign URLs of the custom endpoint"))
+	}
+
+	if client.Config.Endpoint == "" {
+		contentDisposition := options.ContentDisposition(path)
+		if client.Config.ACL == s3.BucketCannedACLPrivate || client.Config.ACL == s3.BucketCannedACLAuthenticatedRead ||
+			contentDisposition != "" || options.ResponseContentType != "" {
+			input := &s3.GetObjectInput{
 				Bucket: aws.String(client.Config.Bucket),
 				Key:    aws.String(client.ToRelativePath(path)),
-			})
+			}
+			if contentDisposition != "" {
+				input.ResponseContentDisposition = aws.String(contentDisposition)
+			}
+			if options.ResponseContentType != "" {
+				input.ResponseContentType = aws.String(options.ResponseContentType)
+			}
 
-			return getResponse.Presign(1 * time.Hour)
+			getResponse, _ := client.S3.GetObjectRequest(input)
+			return getResponse.Presign(options.GetExpiry())
 		}
 	}
 
@@ -15,10 +15,20 @@
 package s3_test
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/casdoor/oss"
 	"github.com/casdoor/oss/s3"
 	"github.com/casdoor/oss/tests"
 	"github.com/jinzhu/configor"
@@ -90,3 +100,185 @@ func TestToRelativePathWithS3ForcePathStyle(t *testing.T) {
 		}
 	}
 }
+
+func TestGetURLWithOptionsWithEndpoint(t *testing.T) {
+	client := s3.New(&s3.Config{AccessID: "access_id", AccessKey: "access_key", Region: "us-east-1", Bucket: "bucket", Endpoint: "https://cdn.example.com"})
+	if url, err := client.GetURLWithOptions("/sample.txt", &oss.URLOptions{}); err != nil || url != "/sample.txt" {
+		t.Errorf("URL of the endpoint should be got without options, but got %v %v", url, err)
+	}
+	for _, options := range []*oss.URLOptions{{Expiry: time.Minute}, {Download: true}, {ResponseContentType: "text/plain"}} {
+		if _, err := client.GetURLWithOptions("/sample.txt", options); !errors.Is(err, oss.ErrUnsupported) {
+			t.Errorf("Options %+v should be unsupported with the endpoint, but got %v", options, err)
+		}
+	}
+}
+
+func TestGetURLWithOptionsPresigned(t *testing.T) {
+	client := s3.New(&s3.Config{AccessID: "access_id", AccessKey: "access_key", Region: "us-east-1", Bucket: "bucket", ACL: awss3.BucketCannedACLPrivate})
+	rawURL, err := client.GetURL("/sample.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get URL, but got %v", err)
+	}
+	if u, _ := url.Parse(rawURL); u.Query().Get("X-Amz-Signature") == "" || u.Query().Get("X-Amz-Expires") != "3600" || !strings.HasSuffix(u.Path, "/sample.txt") {
+		t.Errorf("URL of private object should be presigned for an hour, but got %v", rawURL)
+	}
+
+	client.Config.ACL = awss3.BucketCannedACLPublicRead
+	rawURL, err = client.GetURLWithOptions("/sample.txt", &oss.URLOptions{Expiry: time.Minute, Download: true, ResponseContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("No error should happen when get URL with options, but got %v", err)
+	}
+	u, _ := url.Parse(rawURL)
+	if query := u.Query(); query.Get("X-Amz-Signature") == "" || query.Get("X-Amz-Expires") != "60" ||
+		!strings.Contains(query.Get("response-content-disposition"), "attachment") || query.Get("response-content-type") != "text/plain" {
+		t.Errorf("URL with options should be presigned for a minute with the response overridden, but got %v", rawURL)
+	}
+}
+
+func TestGetUploadURLDefaultExpiry(t *testing.T) {
+	client := s3.New(&s3.Config{AccessID: "access_id", AccessKey: "access_key", Region: "us-east-1", Bucket: "bucket"})
+	for _, expiry := range []time.Duration{0, -time.Minute} {
+		rawURL, err := client.GetUploadURL("/upload.txt", expiry, "text/plain")
+		if err != nil {
+			t.Fatalf("No error should happen when get upload URL, but got %v", err)
+		}
+		if u, _ := url.Parse(rawURL); u.Query().Get("X-Amz-Expires") != "3600" {
+			t.Errorf("Upload URL with expiry %v should be presigned for an hour, but got %v", expiry, rawURL)
+		}
+	}
+}
+
+// fakeMultipartServer is a minimal S3 server for multipart uploads, it fails the upload of failPart if it's not zero
+type fakeMultipartServer struct {
+	sync.Mutex
+	failPart    string
+	contentType string
+	parts       map[string]int
+	aborted     bool
+	completed   bool
+}
+
+func (server *fakeMultipartServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.Lock()
+	defer server.Unlock()
+
+	query := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		server.contentType = r.Header.Get("Content-Type")
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload-id</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && query.Get("partNumber") != "":
+		if query.Get("partNumber") == server.failPart {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<Error><Code>InternalError</Code><Message>failed</Message></Error>`)
+			return
+		}
+		server.parts[query.Get("partNumber")] = len(body)
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		server.completed = true
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+	case r.Method == http.MethodHead:
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Content-Length", "0")
+	case r.Method == http.MethodDelete && query.Get("uploadId") != "":
+		server.aborted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func newFakeMultipartClient(server *httptest.Server) *s3.Client {
+	return s3.New(&s3.Config{
+		AccessID:         "access_id",
+		AccessKey:        "access_key",
+		Region:           "us-east-1",
+		Bucket:           "bucket",
+		S3Endpoint:       server.URL,
+		S3ForcePathStyle: true,
+		PartSize:         5 * 1024 * 1024,
+		Concurrency:      2,
+	})
+}
+
+func TestPutMultipart(t *testing.T) {
+	fake := &fakeMultipartServer{parts: map[string]int{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	// a reader without Seek, which couldn't be read at once by the uploader
+	content := strings.Repeat("<html>", 2*1024*1024)
+	reader := struct{ io.Reader }{strings.NewReader(content)}
+
+	object, err := newFakeMultipartClient(server).Put("large", reader)
+	if err != nil {
+		t.Fatalf("No error should happen when put large file, but got %v", err)
+	}
+	if object.Size != int64(len(content)) {
+		t.Errorf("Size should be %v, but got %v", len(content), object.Size)
+	}
+	if object.Path != "/large" {
+		t.Errorf("Path should start with a slash like Stat and List, but got %v", object.Path)
+	}
+	if stat, err := newFakeMultipartClient(server).Stat("large"); err != nil || stat.Path != object.Path {
+		t.Errorf("Path of Stat should be the same as Put, but got %+v %v", stat, err)
+	}
+	if len(fake.parts) != 3 || !fake.completed {
+		t.Errorf("Large file should be uploaded in 3 parts, but got %v", fake.parts)
+	}
+	if !strings.HasPrefix(fake.contentType, "text/html") {
+		t.Errorf("Content type should be sniffed from the first chunk, but got %v", fake.contentType)
+	}
+}
+
+func TestPutMultipartAbort(t *testing.T) {
+	fake := &fakeMultipartServer{parts: map[string]int{}, failPart: "2"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	content := strings.Repeat("sample", 2*1024*1024)
+	reader := struct{ io.Reader }{strings.NewReader(content)}
+
+	if _, err := newFakeMultipartClient(server).Put("/large", reader); err == nil {
+		t.Errorf("There should be an error when a part fails to upload")
+	}
+	if !fake.aborted || fake.completed {
+		t.Errorf("Multipart upload should be aborted when a part fails to upload")
+	}
+}
+
+func TestMultipartUploader(t *testing.T) {
+	fake := &fakeMultipartServer{parts: map[string]int{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := newFakeMultipartClient(server)
+
+	uploadID, err := client.InitiateUpload("/large.txt")
+	if err != nil || uploadID != "upload-id" {
+		t.Fatalf("Upload ID should be returned when initiate upload, but got %v, %v", uploadID, err)
+	}
+
+	var parts []*oss.Part
+	for number, content := range []string{"part 1", "part 2"} {
+		part, err := client.UploadPart("/large.txt", uploadID, number+1, struct{ io.Reader }{strings.NewReader(content)})
+		if err != nil {
+			t.Fatalf("No error should happen when upload part, but got %v", err)
+		}
+		if part.ETag != "etag" || part.Size != int64(len(content)) {
+			t.Errorf("Uploaded part should have ETag and size, but got %+v", part)
+		}
+		parts = append(parts, part)
+	}
+
+	if object, err := client.CompleteUpload("/large.txt", uploadID, parts); err != nil {
+		t.Errorf("No error should happen when complete upload, but got %v", err)
+	} else if object.Size != 12 || !fake.completed {
+		t.Errorf("Upload should be completed with size 12, but got %v", object.Size)
+	}
+
+	if err := client.AbortUpload("/large.txt", uploadID); err != nil || !fake.aborted {
+		t.Errorf("No error should happen when abort upload, but got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package synology
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/casdoor/oss"
+)
+
+// APIError error reported by DSM with `success: false`, it's wrapped with oss errors like oss.ErrNotFound
+// if the code has a corresponding one, and is still available with errors.As
+type APIError struct {
+	// API name of the API, like SYNO.FileStation.Upload
+	API string
+	// Code DSM error code, whose meaning depends on the API for codes from 400
+	Code int
+	// Path file or folder which the error is about, empty if it's not about a file
+	Path string
+}
+
+func (err *APIError) Error() string {
+	if err.Path != "" {
+		return fmt.Sprintf("synology: %s failed for %s: %s (error code %d)", err.API, err.Path, err.Message(), err.Code)
+	}
+	return fmt.Sprintf("synology: %s failed: %s (error code %d)", err.API, err.Message(), err.Code)
+}
+
+// Message get the description of the error code in the documents of DSM
+func (err *APIError) Message() string {
+	if message, ok := commonErrorMessages[err.Code]; ok {
+		return message
+	}
+	messages := fileStationErrorMessages
+	if strings.HasPrefix(err.API, "SYNO.API.Auth") {
+		messages = authErrorMessages
+	}
+	if message, ok := messages[err.Code]; ok {
+		return message
+	}
+	return "Unknown error"
+}
+
+// commonErrorMessages error codes shared by all APIs
+var commonErrorMessages = map[int]string{
+	100: "Unknown error",
+	101: "No parameter of API, method or version",
+	102: "The requested API does not exist",
+	103: "The requested method does not exist",
+	104: "The requested version does not support the functionality",
+	105: "The logged in session does not have permission",
+	106: "Session timeout",
+	107: "Session interrupted by duplicated login",
+	119: "SID not found",
+}
+
+// authErrorMessages error codes of SYNO.API.Auth
+var authErrorMessages = map[int]string{
+	400: "No such account or incorrect password",
+	401: "Account disabled",
+	402: "Permission denied",
+	403: "2-step verification code required",
+	404: "Failed to authenticate 2-step verification code",
+	406: "Enforce to authenticate with 2-factor authentication code",
+	407: "Blocked IP source",
+	408: "Expired password cannot change",
+	409: "Expired password",
+	410: "Password must be changed",
+}
+
+// fileStationErrorMessages error codes of SYNO.FileStation APIs
+var fileStationErrorMessages = map[int]string{
+	400:  "Invalid parameter of file operation",
+	401:  "Unknown error of file operation",
+	402:  "System is too busy",
+	403:  "Invalid user does this file operation",
+	404:  "Invalid group does this file operation",
+	405:  "Invalid user and group does this file operation",
+	406:  "Can't get user/group information from the account server",
+	407:  "Operation not permitted",
+	408:  "No such file or directory",
+	409:  "Non-supported file system",
+	410:  "Failed to connect internet-based file system",
+	411:  "Read-only file system",
+	412:  "Filename too long in the non-encrypted file system",
+	413:  "Filename too long in the encrypted file system",
+	414:  "File already exists",
+	415:  "Disk quota exceeded",
+	416:  "No space left on device",
+	417:  "Input/output error",
+	418:  "Illegal name or path",
+	419:  "Illegal file name",
+	420:  "Illegal file name on FAT file system",
+	421:  "Device or resource busy",
+	599:  "No such task of the file operation",
+	900:  "Failed to delete file(s)/folder(s)",
+	1000: "Failed to copy files/folders",
+	1001: "Failed to move files/folders",
+	1002: "An error occurred at the destination",
+	1003: "Cannot overwrite or skip the existing file because no overwrite parameter is given",
+	1004: "File cannot overwrite a folder with the same name, or folder cannot overwrite a file with the same name",
+	1006: "Cannot copy/move file/folder with special characters to a FAT32 file system",
+	1007: "Cannot copy/move a file bigger than 4G to a FAT32 file system",
+	1100: "Failed to create a folder",
+	1101: "The number of folders to the parent folder would exceed the system limitation",
+	1200: "Failed to rename it",
+	1800: "The received size doesn't match the Content-Length of the upload",
+	1801: "Wait too long, no data can be received from client",
+	1802: "No filename information in the last part of file content",
+	1803: "Upload connection is cancelled",
+	1804: "Failed to upload oversized file to FAT file system",
+	1805: "Can't overwrite or skip the existing file, if no overwrite parameter is given",
+}
+
+// statusError wrap err with oss errors according to the HTTP status code
+func statusError(code int, err error) error {
+	switch code {
+	case http.StatusNotFound:
+		return oss.NewError(oss.ErrNotFound, err)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return oss.NewError(oss.ErrPermission, err)
+	}
+	return err
+}
+
+// codeError wrap err with oss errors according to its DSM error code
+func codeError(err *APIError) error {
+	switch err.Code {
+	case 105, 106, 107, 119: // session errors, which remain after logging in again
+		return oss.NewError(oss.ErrPermission, err)
+	}
+
+	if strings.HasPrefix(err.API, "SYNO.API.Auth") {
+		if err.Code >= 400 && err.Code <= 410 {
+			return oss.NewError(oss.ErrPermission, err)
+		}
+		return err
+	}
+	switch err.Code {
+	case 408: // No such file or directory
+		return oss.NewError(oss.ErrNotFound, err)
+	case 403, 404, 405, 407: // Invalid user or group, Operation not permitted
+		return oss.NewError(oss.ErrPermission, err)
+	case 414, 1003, 1805: // File already exists
+		return oss.NewError(oss.ErrAlreadyExists, err)
+	}
+	return err
+}
@@ -0,0 +1,364 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package synology_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFile file stored by fakeDSM
+type fakeFile struct {
+	content []byte
+	mtime   int64
+	crtime  int64
+}
+
+// fakeDSM a stand-in of the web API of DSM and FileStation, which stores files in memory.
+// The account is "admin" with password "password", sessions are valid until Expire is called
+type fakeDSM struct {
+	*httptest.Server
+
+	mutex sync.Mutex
+	files map[string]*fakeFile
+	// folders created by CreateFolder, folders are also implied by the files in them
+	folders  map[string]bool
+	sessions map[string]bool
+	expired  map[string]bool
+	logins   int
+	tasks    int
+	// deleting paths of delete tasks, files are deleted after the status of the task is checked deletePolls times
+	deleting    map[string][]string
+	deletePolls int
+	// uploadLengths Content-Length of uploads, -1 if the body is chunked
+	uploadLengths []int64
+}
+
+func newFakeDSM(t *testing.T) *fakeDSM {
+	dsm := &fakeDSM{files: map[string]*fakeFile{}, folders: map[string]bool{}, sessions: map[string]bool{}, expired: map[string]bool{}, deleting: map[string][]string{}}
+	dsm.Server = httptest.NewServer(dsm)
+	t.Cleanup(dsm.Close)
+	return dsm
+}
+
+// Expire expire all sessions, DSM reports error 106 for them then
+func (dsm *fakeDSM) Expire() {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+	for sid := range dsm.sessions {
+		dsm.expired[sid] = true
+	}
+	dsm.sessions = map[string]bool{}
+}
+
+// Logins get the count of successful logins
+func (dsm *fakeDSM) Logins() int {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+	return dsm.logins
+}
+
+// Sessions get the count of valid sessions
+func (dsm *fakeDSM) Sessions() int {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+	return len(dsm.sessions)
+}
+
+func (dsm *fakeDSM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dsm.mutex.Lock()
+	defer dsm.mutex.Unlock()
+
+	query := r.URL.Query()
+	switch r.URL.Path {
+	case "/webapi/query.cgi":
+		dsm.success(w, map[string]interface{}{})
+	case "/webapi/auth.cgi":
+		dsm.serveAuth(w, query)
+	case "/webapi/entry.cgi":
+		sid := query.Get("_sid")
+		if cookie, err := r.Cookie("id"); err == nil && sid == "" {
+			sid = cookie.Value
+		}
+		if !dsm.sessions[sid] {
+			if dsm.expired[sid] {
+				dsm.fail(w, 106)
+			} else {
+				dsm.fail(w, 119)
+			}
+			return
+		}
+		if r.Method == http.MethodPost {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				dsm.fail(w, 101)
+				return
+			}
+			for key, values := range r.MultipartForm.Value {
+				query[key] = values
+			}
+		}
+		dsm.serveFileStation(w, r, query)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (dsm *fakeDSM) serveAuth(w http.ResponseWriter, query map[string][]string) {
+	get := func(key string) string {
+		if values := query[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	switch get("method") {
+	case "login":
+		if get("account") != "admin" || get("passwd") != "password" {
+			dsm.fail(w, 400)
+			return
+		}
+		dsm.logins++
+		sid := fmt.Sprintf("sid-%d", dsm.logins)
+		dsm.sessions[sid] = true
+		dsm.success(w, map[string]interface{}{"sid": sid, "synotoken": fmt.Sprintf("token-%d", dsm.logins)})
+	case "logout":
+		delete(dsm.sessions, get("_sid"))
+		dsm.success(w, nil)
+	default:
+		dsm.fail(w, 103)
+	}
+}
+
+func (dsm *fakeDSM) serveFileStation(w http.ResponseWriter, r *http.Request, query map[string][]string) {
+	get := func(key string) string {
+		if values := query[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	switch get("api") + "." + get("method") {
+	case "SYNO.FileStation.Upload.upload":
+		dsm.uploadLengths = append(dsm.uploadLengths, r.ContentLength)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			dsm.fail(w, 401)
+			return
+		}
+		content, _ := ioutil.ReadAll(file)
+		filePath := path.Join(get("path"), header.Filename)
+		if _, ok := dsm.files[filePath]; ok && get("overwrite") != "true" {
+			dsm.fail(w, 414)
+			return
+		}
+		mtime, _ := strconv.ParseInt(get("mtime"), 10, 64)
+		crtime, _ := strconv.ParseInt(get("crtime"), 10, 64)
+		if mtime == 0 {
+			mtime = time.Now().UnixNano() / int64(time.Millisecond)
+		}
+		dsm.files[filePath] = &fakeFile{content: content, mtime: mtime / 1000, crtime: crtime / 1000}
+		dsm.success(w, nil)
+	case "SYNO.FileStation.Download.download":
+		file, ok := dsm.files[get("path")]
+		if !ok {
+			dsm.fail(w, 408)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment; filename="+path.Base(get("path")))
+		w.Write(file.content)
+	case "SYNO.FileStation.List.list":
+		dsm.serveList(w, get)
+	case "SYNO.FileStation.List.getinfo":
+		var paths []string
+		json.Unmarshal([]byte(get("path")), &paths)
+		var files []map[string]interface{}
+		for _, filePath := range paths {
+			if info, ok := dsm.fileInfo(filePath, true); ok {
+				files = append(files, info)
+			} else {
+				files = append(files, map[string]interface{}{"code": 408, "path": filePath, "name": path.Base(filePath)})
+			}
+		}
+		dsm.success(w, map[string]interface{}{"files": files})
+	case "SYNO.FileStation.Delete.start":
+		var paths []string
+		if err := json.Unmarshal([]byte(get("path")), &paths); err != nil {
+			paths = []string{get("path")}
+		}
+		dsm.tasks++
+		taskID := fmt.Sprintf("FileStation_%d", dsm.tasks)
+		dsm.deleting[taskID] = paths
+		dsm.success(w, map[string]interface{}{"taskid": taskID})
+	case "SYNO.FileStation.Delete.status":
+		paths, ok := dsm.deleting[get("taskid")]
+		if !ok {
+			dsm.fail(w, 599)
+			return
+		}
+		if dsm.deletePolls > 0 {
+			dsm.deletePolls--
+			dsm.success(w, map[string]interface{}{"finished": false})
+			return
+		}
+		delete(dsm.deleting, get("taskid"))
+		for _, deletingPath := range paths {
+			found := dsm.folders[deletingPath]
+			for folder := range dsm.folders {
+				if folder == deletingPath || strings.HasPrefix(folder, deletingPath+"/") {
+					delete(dsm.folders, folder)
+				}
+			}
+			for filePath := range dsm.files {
+				if filePath == deletingPath || strings.HasPrefix(filePath, deletingPath+"/") {
+					delete(dsm.files, filePath)
+					found = true
+				}
+			}
+			if !found {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": map[string]interface{}{
+					"code": 900, "errors": []interface{}{map[string]interface{}{"code": 408, "path": deletingPath}},
+				}})
+				return
+			}
+		}
+		dsm.success(w, map[string]interface{}{"finished": true})
+	case "SYNO.FileStation.CopyMove.status":
+		dsm.success(w, map[string]interface{}{"finished": true})
+	case "SYNO.FileStation.CreateFolder.create":
+		for folder := path.Join(get("folder_path"), get("name")); folder != "/"; folder = path.Dir(folder) {
+			dsm.folders[folder] = true
+		}
+		dsm.success(w, map[string]interface{}{"folders": []interface{}{}})
+	case "SYNO.FileStation.CopyMove.start":
+		file, ok := dsm.files[get("path")]
+		if !ok {
+			dsm.fail(w, 408)
+			return
+		}
+		if _, ok := dsm.fileInfo(get("dest_folder_path"), false); !ok && !dsm.folders[get("dest_folder_path")] {
+			dsm.fail(w, 408)
+			return
+		}
+		copied := *file
+		dsm.files[path.Join(get("dest_folder_path"), path.Base(get("path")))] = &copied
+		if get("remove_src") == "true" {
+			delete(dsm.files, get("path"))
+		}
+		dsm.tasks++
+		dsm.success(w, map[string]interface{}{"taskid": fmt.Sprintf("FileStation_%d", dsm.tasks)})
+	case "SYNO.FileStation.Rename.rename":
+		file, ok := dsm.files[get("path")]
+		if !ok {
+			dsm.fail(w, 408)
+			return
+		}
+		target := path.Join(path.Dir(get("path")), get("name"))
+		if _, ok := dsm.files[target]; ok {
+			dsm.fail(w, 414)
+			return
+		}
+		delete(dsm.files, get("path"))
+		dsm.files[target] = file
+		dsm.success(w, nil)
+	default:
+		dsm.fail(w, 102)
+	}
+}
+
+// serveList list files and folders under folder_path, which are paged by offset and limit
+func (dsm *fakeDSM) serveList(w http.ResponseWriter, get func(string) string) {
+	folder := strings.TrimSuffix(path.Clean(get("folder_path")), "/")
+	children := map[string]bool{}
+	for filePath := range dsm.files {
+		if rest := strings.TrimPrefix(filePath, folder+"/"); rest != filePath {
+			name, _, _ := strings.Cut(rest, "/")
+			children[path.Join(folder, name)] = true
+		}
+	}
+	if len(children) == 0 {
+		dsm.fail(w, 408)
+		return
+	}
+
+	var paths []string
+	for childPath := range children {
+		paths = append(paths, childPath)
+	}
+	sort.Strings(paths)
+
+	offset, _ := strconv.Atoi(get("offset"))
+	limit, _ := strconv.Atoi(get("limit"))
+	total := len(paths)
+	if offset > len(paths) {
+		offset = len(paths)
+	}
+	paths = paths[offset:]
+	if limit > 0 && limit < len(paths) {
+		paths = paths[:limit]
+	}
+
+	files := []map[string]interface{}{}
+	for _, childPath := range paths {
+		info, _ := dsm.fileInfo(childPath, strings.Contains(get("additional"), "size"))
+		files = append(files, info)
+	}
+	dsm.success(w, map[string]interface{}{"total": total, "offset": offset, "files": files})
+}
+
+// fileInfo get the entry of a file or folder returned by List
+func (dsm *fakeDSM) fileInfo(filePath string, additional bool) (map[string]interface{}, bool) {
+	info := map[string]interface{}{"path": filePath, "name": path.Base(filePath)}
+	if file, ok := dsm.files[filePath]; ok {
+		info["isdir"] = false
+		if additional {
+			info["additional"] = map[string]interface{}{
+				"size": len(file.content),
+				"time": map[string]interface{}{"mtime": file.mtime, "crtime": file.crtime},
+			}
+		}
+		return info, true
+	}
+	for otherPath := range dsm.files {
+		if strings.HasPrefix(otherPath, filePath+"/") {
+			info["isdir"] = true
+			if additional {
+				info["additional"] = map[string]interface{}{"size": 0, "time": map[string]interface{}{}}
+			}
+			return info, true
+		}
+	}
+	return nil, false
+}
+
+func (dsm *fakeDSM) success(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": data})
+}
+
+func (dsm *fakeDSM) fail(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": map[string]interface{}{"code": code}})
+}
@@ -16,42 +16,87 @@ package synology
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
-	"io/ioutil"
 
-	"mime/multipart"
 	"github.com/casdoor/oss"
 )
 
-// Client Synology NAS storage
+var (
+	_ oss.ContextStorage = (*Client)(nil)
+	_ oss.Stater         = (*Client)(nil)
+	_ oss.OptionPutter   = (*Client)(nil)
+	_ oss.Copier         = (*Client)(nil)
+	_ oss.Walker         = (*Client)(nil)
+)
+
+const (
+	// taskPollInterval interval of checking the status of a background task of FileStation
+	taskPollInterval = 500 * time.Millisecond
+	// listPageSize count of files listed in a request of SYNO.FileStation.List
+	listPageSize = 1000
+	// defaultTaskTimeout max time of waiting for a background task if Config.TaskTimeout isn't specified
+	defaultTaskTimeout = 5 * time.Minute
+)
+
+// sessionErrorCodes DSM error codes of invalid sessions, requests are retried once after logging in again
+var sessionErrorCodes = map[int]bool{
+	105: true, // The logged in session does not have permission
+	106: true, // Session timeout
+	107: true, // Session interrupted by duplicated login
+	119: true, // SID not found
+}
+
+// Client Synology NAS storage, it's safe for concurrent use as the session is refreshed under a lock
 type Client struct {
-	Config        *Config
-	SID       string
-	SynoToken string
-	AppAPIList map[string]map[string]interface{}
+	Config      *Config
+	SID         string
+	SynoToken   string
+	AppAPIList  map[string]map[string]interface{}
 	FullAPIList map[string]map[string]interface{}
+
+	// mutex guards SID, SynoToken and Config.SessionExpire
+	mutex sync.RWMutex
+	// application the session is logged in for
+	application string
 }
 
 // Config Synology NAS client config
 type Config struct {
-	Endpoint string
-	AccessID string
+	Endpoint  string
+	AccessID  string
 	AccessKey string
+	// SessionExpire is set when DSM reports the session is invalid, Login starts a new session then
 	SessionExpire bool
-	Verify   bool
-	Debug   bool
-	OtpCode string
-	SharedFolder string
+	Verify        bool
+	Debug         bool
+	OtpCode       string
+	SharedFolder  string
+	// TaskTimeout max time of waiting for a background task of FileStation, like deleting or copying files,
+	// defaultTaskTimeout if zero
+	TaskTimeout time.Duration
+	// DisableOverwrite fail uploading with oss.ErrAlreadyExists instead of overwriting if the file exists
+	DisableOverwrite bool
 }
 
+// New create a client of DSM and log in FileStation. Errors of logging in are ignored and the client
+// logs in again with its first request, use NewWithError to get the error at once
 func New(config *Config) *Client {
 	client := &Client{Config: config}
 	client.Login("FileStation")
@@ -59,10 +104,68 @@ func New(config *Config) *Client {
 	return client
 }
 
+// NewWithError create a client of DSM and log in FileStation, the error of logging in is returned if any
+func NewWithError(config *Config) (*Client, error) {
+	client := &Client{Config: config}
+	if err := client.Login("FileStation"); err != nil {
+		return nil, err
+	}
+	if err := client.GetAPIList("FileStation"); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newRequest create a request to DSM with the headers of the session sid
+func (client *Client) newRequest(ctx context.Context, method string, url string, body io.Reader, sid, synoToken string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accept-Encoding is left to net/http, so compressed responses are decoded transparently
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-CN;q=0.8,zh;q=0.7")
+	req.Header.Set("Connection", "keep-alive")
+	req.Header.Set("Cookie", "stay_login=1; id="+sid)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	req.Header.Set("X-SYNO-TOKEN", synoToken) // not necessary
+
+	return req, nil
+}
+
+// session get the SID and SynoToken of current session
+func (client *Client) session() (sid, synoToken string) {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return client.SID, client.SynoToken
+}
+
+// refreshSession log in again if DSM reports the session sid is invalid,
+// nothing is done if the session has been refreshed by another request meanwhile
+func (client *Client) refreshSession(ctx context.Context, sid string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.SID != sid && client.SID != "" {
+		return nil
+	}
+	client.Config.SessionExpire = true
+	application := client.application
+	if application == "" {
+		application = "FileStation"
+	}
+	return client.login(ctx, application)
+}
 
 // Get receive file with given path
-func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+func (client *Client) Get(path string) (file *os.File, err error) {
+	return client.GetContext(context.Background(), path)
+}
+
+// GetContext receive file with given path
+func (client *Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamContext(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -77,84 +180,100 @@ func (client Client) Get(path string) (file *os.File, err error) {
 }
 
 // GetStream get file as stream
-func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	sharedFolder := client.Config.SharedFolder
-	baseURL := client.Config.Endpoint + "/webapi/entry.cgi"
-	path = filepath.ToSlash(path)
+func (client *Client) GetStream(path string) (io.ReadCloser, error) {
+	return client.GetStreamContext(context.Background(), path)
+}
 
-	if path == "" {
-		return nil, fmt.Errorf("path is empty")
-	}
-	apiName := "SYNO.FileStation.Download"
+// GetStreamContext get file as stream, it's downloaded again in a new session if the session is expired
+func (client *Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	for retried := false; ; retried = true {
+		sid, synoToken := client.session()
+		url, err := client.downloadURL(path, sid, synoToken)
+		if err != nil {
+			return nil, err
+		}
 
+		req, err := client.newRequest(ctx, "GET", url, nil, sid, synoToken)
+		if err != nil {
+			return nil, err
+		}
 
-	params := url.Values{}
-	params.Set("api", apiName)
-	params.Set("version", "2")
-	params.Set("method", "download")
-	params.Set("path", sharedFolder + path)
-	params.Set("mode", "download")
-	params.Set("SynoToken", client.SynoToken)
-	params.Set("_sid", client.SID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
 
-	url := baseURL + "?" + params.Encode()
-	
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, statusError(resp.StatusCode, fmt.Errorf("download failed, status code: %d", resp.StatusCode))
+		}
+
+		body, code, err := downloadError(resp)
+		if err == nil {
+			return body, nil
+		}
+		if sessionErrorCodes[code] && !retried {
+			if err := client.refreshSession(ctx, sid); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		return nil, err
 	}
+}
 
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-CN;q=0.8,zh;q=0.7")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Cookie", "stay_login=1; id="+client.SID)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("X-SYNO-TOKEN", client.SynoToken) // not necessary
+// downloadError check whether DSM responds a download with an error, which is a small JSON instead of the file.
+// The body of the file is returned if there is no error
+func downloadError(resp *http.Response) (io.ReadCloser, int, error) {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") || resp.ContentLength < 0 || resp.ContentLength > 1024 {
+		return resp.Body, 0, nil
+	}
 
-	resp, err := http.Get(url)
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download failed, status code: %d", resp.StatusCode)
+	var responseJSON struct {
+		Success *bool `json:"success"`
+		Error   struct {
+			Code int `json:"code"`
+		} `json:"error"`
 	}
-
-	return resp.Body, err
+	if json.Unmarshal(content, &responseJSON) == nil && responseJSON.Success != nil && !*responseJSON.Success {
+		code := responseJSON.Error.Code
+		return nil, code, codeError(&APIError{API: "SYNO.FileStation.Download", Code: code})
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), 0, nil
 }
 
-
 func (client *Client) GetAPIList(app string) error {
+	return client.GetAPIListContext(context.Background(), app)
+}
+
+func (client *Client) GetAPIListContext(ctx context.Context, app string) error {
 	baseURL := client.Config.Endpoint + "/webapi/"
 	queryPath := "query.cgi?api=SYNO.API.Info"
 	params := url.Values{}
 	params.Set("version", "1")
 	params.Set("method", "query")
 	params.Set("query", "all")
-	
-	response, err := http.Get(baseURL + queryPath + "&" + params.Encode())
 
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+queryPath+"&"+params.Encode(), nil)
 	if err != nil {
 		return err
 	}
-	defer response.Body.Close()
-
-	if response.StatusCode != http.StatusOK {
-		return err
-	}
-
-	var responseJSON map[string]interface{}
-	err = json.NewDecoder(response.Body).Decode(&responseJSON)
 
+	response, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
+
 	responseJSONTwoLevel := make(map[string]map[string]interface{})
-	for key, value := range responseJSON["data"].(map[string]interface{}) {
-		if innerMap, ok := value.(map[string]interface{}); ok {
-			responseJSONTwoLevel[key] = innerMap
-		}
+	if _, err = decodeResponse(response, "SYNO.API.Info", &responseJSONTwoLevel); err != nil {
+		return err
 	}
 
 	client.AppAPIList = make(map[string]map[string]interface{})
@@ -171,8 +290,19 @@ func (client *Client) GetAPIList(app string) error {
 	return nil
 }
 
-
 func (client *Client) Login(application string) error {
+	return client.LoginContext(context.Background(), application)
+}
+
+// LoginContext log in DSM for application, nothing is done if the session is still valid
+func (client *Client) LoginContext(ctx context.Context, application string) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.login(ctx, application)
+}
+
+// login log in DSM, client.mutex should be held
+func (client *Client) login(ctx context.Context, application string) error {
 	baseURL := client.Config.Endpoint + "/webapi/"
 	loginAPI := "auth.cgi?api=SYNO.API.Auth"
 	params := url.Values{}
@@ -189,289 +319,472 @@ func (client *Client) Login(application string) error {
 	}
 	loginAPI = loginAPI + "&" + params.Encode()
 
-	var sessionRequestJSON map[string]interface{}
 	if !client.Config.SessionExpire && client.SID != "" {
-		client.Config.SessionExpire = false
 		if client.Config.Debug {
 			fmt.Println("User already logged in")
 		}
-	} else {
-		// Check request for error:
-		response, err := http.Get(baseURL + loginAPI)
-		if err != nil {
-			return err
-		}
-
-		defer response.Body.Close()
+		return nil
+	}
 
-		if response.StatusCode != http.StatusOK {
-			return err
-		}
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+loginAPI, nil)
+	if err != nil {
+		return err
+	}
 
-		err = json.NewDecoder(response.Body).Decode(&sessionRequestJSON)
-		if err != nil {
-			return err
-		}
+	// Check request for error:
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
 	}
-	
-	// Check DSM response for error:
-	errorCode := client.getErrorCode(sessionRequestJSON)
+	defer response.Body.Close()
 
-	if errorCode == 0 {
-		client.SID = sessionRequestJSON["data"].(map[string]interface{})["sid"].(string)
-		client.SynoToken = sessionRequestJSON["data"].(map[string]interface{})["synotoken"].(string)
-		client.Config.SessionExpire = false
-		if client.Config.Debug {
-			fmt.Println("User logged in, new session started!")
-		}
-	} else {
+	// Check DSM response for error:
+	var data struct {
+		SID       string `json:"sid"`
+		SynoToken string `json:"synotoken"`
+	}
+	if _, err = decodeResponse(response, "SYNO.API.Auth", &data); err != nil {
 		client.SID = ""
 		if client.Config.Debug {
 			fmt.Println("User logged faild")
 		}
+		return err
 	}
 
+	client.SID = data.SID
+	client.SynoToken = data.SynoToken
+	client.Config.SessionExpire = false
+	client.application = application
+	if client.Config.Debug {
+		fmt.Println("User logged in, new session started!")
+	}
 	return nil
-
 }
 
-func (client Client) getErrorCode(response map[string]interface{}) int {
-
-	var code int
-	if response["success"].(bool) {
-		code = 0 // No error
-	} else {
-		errorData := response["error"].(map[string]interface{})
-		code = int(errorData["code"].(float64))
-	}
-
-	return code
+// Logout log out current session of DSM
+func (client *Client) Logout() error {
+	return client.LogoutContext(context.Background())
 }
 
-func (client *Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err error) {
-	sharedFolder := client.Config.SharedFolder
-
-	apiName := "SYNO.FileStation.Upload"
-	baseURL := client.Config.Endpoint + "/webapi/"
-	loginAPI := "entry.cgi"
+// LogoutContext log out current session of DSM, SID and SynoToken are cleared even if DSM fails to log out
+func (client *Client) LogoutContext(ctx context.Context) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
 
+	if client.SID == "" {
+		return nil
+	}
 	params := url.Values{}
-	params.Set("api", apiName)
-	params.Set("version", "2")
-	params.Set("method", "upload")
-	params.Set("SynoToken", client.SynoToken)
-
-	
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
+	params.Set("api", "SYNO.API.Auth")
+	params.Set("version", "3")
+	params.Set("method", "logout")
+	params.Set("session", client.application)
+	params.Set("_sid", client.SID)
 
-	parserURL, err := url.Parse(urlPath)
+	req, err := client.newRequest(ctx, "GET", client.Config.Endpoint+"/webapi/auth.cgi?"+params.Encode(), nil, client.SID, client.SynoToken)
+	client.SID, client.SynoToken = "", ""
+	client.Config.SessionExpire = true
 	if err != nil {
-		fmt.Println("Error parsing URL:", err)
+		return err
 	}
-	path := parserURL.Path
-	dir := filepath.Dir(path)
-	// change windows path to linux path
-	dir = filepath.ToSlash(dir)
 
-	err = writer.WriteField("path", sharedFolder + dir)
-	if err != nil {
-		return nil, err
-	}
-	
-	err = writer.WriteField("overwrite", "true")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer resp.Body.Close()
+	_, err = decodeResponse(resp, params.Get("api"), nil)
+	return err
+}
 
-	err = writer.WriteField("create_parents", "true")
-	if err != nil {
-		return nil, err
-	}
+func (client *Client) Put(urlPath string, reader io.Reader) (r *oss.Object, err error) {
+	return client.PutContext(context.Background(), urlPath, reader)
+}
 
-	filename := filepath.Base(urlPath)
-	part, err := writer.CreateFormFile("file", filename) // Set a placeholder filename
-	if err != nil {
-		return nil, err
-	}
-	_, err = io.Copy(part, reader)
-	if err != nil {
+// PutContext store a reader into given path, see UploadContext for setting the modification time
+func (client *Client) PutContext(ctx context.Context, urlPath string, reader io.Reader) (r *oss.Object, err error) {
+	return client.UploadContext(ctx, urlPath, reader, nil)
+}
+
+// PutWithOptions store a reader into given path, ErrUnsupported is returned if any option is set as FileStation couldn't store them
+func (client *Client) PutWithOptions(urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return client.PutWithOptionsContext(context.Background(), urlPath, reader, options)
+}
+
+// PutWithOptionsContext store a reader into given path, ErrUnsupported is returned if any option is set as FileStation couldn't store them
+func (client *Client) PutWithOptionsContext(ctx context.Context, urlPath string, reader io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	if err := options.CheckSupported("synology"); err != nil {
 		return nil, err
 	}
+	return client.PutContext(ctx, urlPath, reader)
+}
 
-	err = writer.Close()
+// Delete delete file
+func (client *Client) Delete(path string) error {
+	return client.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext delete file, the background task of SYNO.FileStation.Delete is polled until it's finished
+// or Config.TaskTimeout is exceeded
+func (client *Client) DeleteContext(ctx context.Context, path string) error {
+	task, err := client.StartDeleteContext(ctx, path)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return task.WaitContext(ctx)
+}
 
-	url := baseURL + loginAPI + "?" + params.Encode()
+// Copy copy file from src to dst with SYNO.FileStation.CopyMove
+func (client *Client) Copy(src, dst string) error {
+	return client.CopyContext(context.Background(), src, dst)
+}
 
-	req, err := http.NewRequest("POST", url, body)
-	if err != nil {
-		return nil, err
+// CopyContext copy file from src to dst with SYNO.FileStation.CopyMove
+func (client *Client) CopyContext(ctx context.Context, src, dst string) error {
+	return client.copyMove(ctx, src, dst, false)
+}
+
+// Move move file from src to dst with SYNO.FileStation.CopyMove
+func (client *Client) Move(src, dst string) error {
+	return client.MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext move file from src to dst with SYNO.FileStation.CopyMove
+func (client *Client) MoveContext(ctx context.Context, src, dst string) error {
+	return client.copyMove(ctx, src, dst, true)
+}
+
+// copyMove copy or move src to dst, which is overwritten if it exists. CopyMove keeps the name of the file,
+// so if dst has another name, src is copied into a temporary folder next to dst, renamed there and then
+// moved to dst, without touching other files in the folder of dst. src is deleted after it's copied for moving then.
+// Background tasks of CopyMove are polled until they're finished
+func (client *Client) copyMove(ctx context.Context, src, dst string, removeSrc bool) error {
+	src = path.Clean("/" + filepath.ToSlash(src))
+	dst = path.Clean("/" + filepath.ToSlash(dst))
+	dstFolder := path.Dir(dst)
+	if src == dst {
+		return nil
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-CN;q=0.8,zh;q=0.7")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Cookie", "stay_login=1; id="+client.SID)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("X-SYNO-TOKEN", client.SynoToken) // not necessary
-	
-	resp, err := http.DefaultClient.Do(req)
-	
-	if err != nil {
-		return nil, err
+	if path.Base(src) == path.Base(dst) {
+		if err := client.createFolder(ctx, dstFolder); err != nil {
+			return err
+		}
+		return client.copyMoveInto(ctx, src, dstFolder, removeSrc)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("upload failed, status code: %d", resp.StatusCode)
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	tmpFolder := path.Join(dstFolder, ".oss-copy-"+hex.EncodeToString(random))
+	if err := client.createFolder(ctx, tmpFolder); err != nil {
+		return err
+	}
+	err := client.copyMoveInto(ctx, src, tmpFolder, false)
+	if err == nil {
+		err = client.rename(ctx, path.Join(tmpFolder, path.Base(src)), path.Base(dst))
+	}
+	if err == nil {
+		err = client.copyMoveInto(ctx, path.Join(tmpFolder, path.Base(dst)), dstFolder, true)
 	}
+	// the temporary folder only holds a copy, it's deleted even if ctx is canceled
+	if deleteErr := client.DeleteContext(context.Background(), tmpFolder); err == nil {
+		err = deleteErr
+	}
+	if err != nil || !removeSrc {
+		return err
+	}
+	return client.DeleteContext(ctx, src)
+}
 
-	now := time.Now()
-	return &oss.Object{
-		Path: urlPath,
-		Name: filepath.Base(urlPath),
-		LastModified: &now,
-		StorageInterface: client,
-	}, nil
+// createFolder create folder and its parents if they don't exist
+func (client *Client) createFolder(ctx context.Context, folder string) error {
+	if folder == "/" {
+		return nil
+	}
 
+	params := url.Values{}
+	params.Set("api", "SYNO.FileStation.CreateFolder")
+	params.Set("version", "2")
+	params.Set("method", "create")
+	params.Set("folder_path", client.Config.SharedFolder+path.Dir(folder))
+	params.Set("name", path.Base(folder))
+	params.Set("force_parent", "true")
+	return client.callAPI(ctx, params, nil)
 }
 
-// Delete delete file
-func (client Client) Delete(path string) error {
+// copyMoveInto copy or move src into folder with the same name, overwriting the file there
+func (client *Client) copyMoveInto(ctx context.Context, src, folder string, removeSrc bool) error {
 	sharedFolder := client.Config.SharedFolder
 
-	apiName := "SYNO.FileStation.Delete"
-
-	baseURL := client.Config.Endpoint + "/webapi/entry.cgi"
-	path = filepath.ToSlash(path)
-
 	params := url.Values{}
-	params.Set("api", apiName)
-	params.Set("version", "2")
+	params.Set("api", "SYNO.FileStation.CopyMove")
+	params.Set("version", "3")
 	params.Set("method", "start")
-	params.Set("path", sharedFolder + path)
-	params.Set("SynoToken", client.SynoToken)
-	params.Set("_sid", client.SID)
+	params.Set("path", sharedFolder+src)
+	params.Set("dest_folder_path", sharedFolder+folder)
+	params.Set("overwrite", "true")
+	params.Set("remove_src", fmt.Sprint(removeSrc))
 
-	req_url := baseURL + "?" + params.Encode()
-	
-	req, err := http.NewRequest("GET", req_url, nil)
-	if err != nil {
+	var task struct {
+		TaskID string `json:"taskid"`
+	}
+	if err := client.callAPI(ctx, params, &task); err != nil {
 		return err
 	}
+	return client.waitTask(ctx, "SYNO.FileStation.CopyMove", "3", task.TaskID)
+}
 
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-CN;q=0.8,zh;q=0.7")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Cookie", "stay_login=1; id="+client.SID)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("X-SYNO-TOKEN", client.SynoToken) // not necessary
+// rename rename the file of filePath to name in the same folder
+func (client *Client) rename(ctx context.Context, filePath, name string) error {
+	params := url.Values{}
+	params.Set("api", "SYNO.FileStation.Rename")
+	params.Set("version", "2")
+	params.Set("method", "rename")
+	params.Set("path", client.Config.SharedFolder+filePath)
+	params.Set("name", name)
+	return client.callAPI(ctx, params, nil)
+}
 
-	resp, err := http.Get(req_url)
-	if err != nil {
+// callAPI call a DSM web API with current session, data of the response is decoded into data if it's not nil
+func (client *Client) callAPI(ctx context.Context, params url.Values, data interface{}) error {
+	return client.do(ctx, func(sid, synoToken string) (*http.Request, error) {
+		params.Set("SynoToken", synoToken)
+		params.Set("_sid", sid)
+		return client.newRequest(ctx, "GET", client.Config.Endpoint+"/webapi/entry.cgi?"+params.Encode(), nil, sid, synoToken)
+	}, data)
+}
+
+// do send the request built for current session, it's built and sent again after logging in again
+// if DSM reports the session is invalid. Data of the response is decoded into data if it's not nil
+func (client *Client) do(ctx context.Context, newRequest func(sid, synoToken string) (*http.Request, error), data interface{}) error {
+	for retried := false; ; retried = true {
+		sid, synoToken := client.session()
+		req, err := newRequest(sid, synoToken)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		code, err := decodeResponse(resp, req.URL.Query().Get("api"), data)
+		resp.Body.Close()
+
+		if sessionErrorCodes[code] && !retried {
+			if err := client.refreshSession(ctx, sid); err != nil {
+				return err
+			}
+			continue
+		}
 		return err
 	}
+}
 
+// decodeResponse decode the JSON response of DSM, data of the response is decoded into data if it's not nil.
+// The DSM error code is returned if the request fails
+func decodeResponse(resp *http.Response, api string, data interface{}) (int, error) {
 	if resp.StatusCode != http.StatusOK {
-		return err
+		return 0, statusError(resp.StatusCode, fmt.Errorf("%s failed, status code: %d", api, resp.StatusCode))
+	}
+
+	var responseJSON struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Error   struct {
+			Code int `json:"code"`
+			// Errors errors of files of a failed task, like deleting a missing file
+			Errors []struct {
+				Code int    `json:"code"`
+				Path string `json:"path"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&responseJSON); err != nil {
+		return 0, err
+	}
+	if !responseJSON.Success {
+		code := responseJSON.Error.Code
+		if fileErrors := responseJSON.Error.Errors; len(fileErrors) > 0 && fileErrors[0].Code != 0 {
+			// the error of the first file is more specific than the error of the task
+			code = fileErrors[0].Code
+			return code, codeError(&APIError{API: api, Code: code, Path: fileErrors[0].Path})
+		}
+		return code, codeError(&APIError{API: api, Code: code})
 	}
 
-	var responseJSON map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&responseJSON)
-	if err != nil {
-		return err
+	if data != nil && len(responseJSON.Data) > 0 {
+		return 0, json.Unmarshal(responseJSON.Data, data)
 	}
-
-	return nil
+	return 0, nil
 }
 
 // List list all objects under current path
-func (client Client) List(path string) (objects []*oss.Object, err error) {
-	sharedFolder := client.Config.SharedFolder
-
-	apiName := "SYNO.FileStation.List"
+func (client *Client) List(path string) (objects []*oss.Object, err error) {
+	return client.ListContext(context.Background(), path)
+}
 
-	baseURL := client.Config.Endpoint + "/webapi/entry.cgi"
-	path = filepath.ToSlash(path)
+// ListContext list all objects under current path and its subfolders, folders aren't listed
+func (client *Client) ListContext(ctx context.Context, path string) (objects []*oss.Object, err error) {
+	err = client.WalkContext(ctx, path, func(object *oss.Object) error {
+		objects = append(objects, object)
+		return nil
+	})
+	return objects, err
+}
 
-	params := url.Values{}
-	params.Set("api", apiName)
-	params.Set("version", "2")
-	params.Set("method", "list")
-	params.Set("folder_path", sharedFolder + "/" + path)
-	params.Set("SynoToken", client.SynoToken)
-	params.Set("_sid", client.SID)
+// Walk call fn for every file under current path and its subfolders
+func (client *Client) Walk(path string, fn oss.WalkFunc) error {
+	return client.WalkContext(context.Background(), path, fn)
+}
 
-	req_url := baseURL + "?" + params.Encode()
-	
-	req, err := http.NewRequest("GET", req_url, nil)
-	if err != nil {
-		return nil, err
-	}
+// WalkContext call fn for every file under current path and its subfolders, folders are listed page by page
+// and subfolders are walked after files of their parent folder. Nothing is walked if the folder doesn't exist
+func (client *Client) WalkContext(ctx context.Context, urlPath string, fn oss.WalkFunc) error {
+	sharedFolder := client.Config.SharedFolder
+	folders := []string{path.Join(sharedFolder, "/", filepath.ToSlash(urlPath))}
+
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+
+		for offset := 0; ; {
+			params := url.Values{}
+			params.Set("api", "SYNO.FileStation.List")
+			params.Set("version", "2")
+			params.Set("method", "list")
+			params.Set("folder_path", folder)
+			params.Set("additional", `["size","time"]`)
+			params.Set("offset", strconv.Itoa(offset))
+			params.Set("limit", strconv.Itoa(listPageSize))
+
+			var data struct {
+				Total int        `json:"total"`
+				Files []fileInfo `json:"files"`
+			}
+			if err := client.callAPI(ctx, params, &data); err != nil {
+				if errors.Is(err, oss.ErrNotFound) {
+					break
+				}
+				return err
+			}
 
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9,zh-CN;q=0.8,zh;q=0.7")
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Cookie", "stay_login=1; id="+client.SID)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	req.Header.Set("X-SYNO-TOKEN", client.SynoToken) // not necessary
+			for _, file := range data.Files {
+				if file.IsDir {
+					folders = append(folders, file.Path)
+					continue
+				}
+				if err := fn(client.toObject(file)); err == oss.SkipAll {
+					return nil
+				} else if err != nil {
+					return err
+				}
+			}
 
-	resp, err := http.Get(req_url)
-	if err != nil {
-		return nil, err
+			offset += len(data.Files)
+			if len(data.Files) == 0 || offset >= data.Total {
+				break
+			}
+		}
 	}
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, err
+// toObject convert a file entry listed with additional size and time to an object,
+// whose path is relative to the shared folder
+func (client *Client) toObject(file fileInfo) *oss.Object {
+	lastModified := time.Unix(file.Additional.Time.Mtime, 0)
+	return &oss.Object{
+		Path:             strings.TrimPrefix(file.Path, strings.TrimSuffix(client.Config.SharedFolder, "/")),
+		Name:             file.Name,
+		LastModified:     &lastModified,
+		Size:             file.Additional.Size,
+		ContentType:      mime.TypeByExtension(filepath.Ext(file.Name)),
+		StorageInterface: client,
 	}
+}
 
-	var responseJSON map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&responseJSON)
+// fileInfo file entry returned by SYNO.FileStation.List
+type fileInfo struct {
+	Path       string `json:"path"`
+	Name       string `json:"name"`
+	IsDir      bool   `json:"isdir"`
+	Code       int    `json:"code"`
+	Additional struct {
+		Size int64 `json:"size"`
+		Time struct {
+			Mtime int64 `json:"mtime"`
+		} `json:"time"`
+	} `json:"additional"`
+}
+
+// Stat get object's metadata
+func (client *Client) Stat(path string) (*oss.Object, error) {
+	return client.StatContext(context.Background(), path)
+}
+
+// StatContext get object's metadata
+func (client *Client) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	sharedFolder := client.Config.SharedFolder
+	path = filepath.ToSlash(path)
+
+	filePaths, err := json.Marshal([]string{sharedFolder + path})
 	if err != nil {
 		return nil, err
 	}
 
-	for _, content := range responseJSON["data"].(map[string]interface{})["files"].([]interface{}) {
-		now := time.Now()
-		path := content.(map[string]interface{})["path"].(string)
-		// remove top shared path
-		parsedUrl, err := url.Parse(path)
-		if err != nil {
-			return nil, err
-		}
-		pathParts := strings.Split(parsedUrl.Path, "/")
-		if len(pathParts) > 1 {
-			pathParts = append(pathParts[:1], pathParts[2:]...)
-		}
-		parsedUrl.Path = strings.Join(pathParts, "/")
-		path = parsedUrl.String()
+	params := url.Values{}
+	params.Set("api", "SYNO.FileStation.List")
+	params.Set("version", "2")
+	params.Set("method", "getinfo")
+	params.Set("path", string(filePaths))
+	params.Set("additional", `["size","time"]`)
 
-		objects = append(objects, &oss.Object{
-			Path:             path,
-			Name:             filepath.Base(content.(map[string]interface{})["path"].(string)),
-			LastModified:     &now,
-			StorageInterface: &client,
-		})
+	var data struct {
+		Files []fileInfo `json:"files"`
+	}
+	if err := client.callAPI(ctx, params, &data); err != nil {
+		return nil, err
 	}
+	if len(data.Files) == 0 {
+		return nil, fmt.Errorf("getinfo failed for %s", path)
+	}
+	file := data.Files[0]
+	if file.Code != 0 {
+		return nil, codeError(&APIError{API: params.Get("api"), Code: file.Code, Path: path})
+	}
+	lastModified := time.Unix(file.Additional.Time.Mtime, 0)
 
-	return objects, err
+	return &oss.Object{
+		Path:             path,
+		Name:             file.Name,
+		LastModified:     &lastModified,
+		Size:             file.Additional.Size,
+		ContentType:      mime.TypeByExtension(filepath.Ext(file.Name)),
+		StorageInterface: client,
+	}, nil
 }
 
-
 // GetEndpoint get endpoint, FileSystem's endpoint is /
-func (client Client) GetEndpoint() string {
+func (client *Client) GetEndpoint() string {
 	return client.Config.Endpoint
 }
 
 // GetURL get public accessible URL
-func (client Client) GetURL(path string) (get_url string, err error) {
+func (client *Client) GetURL(path string) (get_url string, err error) {
+	return client.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get public accessible URL, which is valid during current session
+func (client *Client) GetURLContext(ctx context.Context, path string) (get_url string, err error) {
+	sid, synoToken := client.session()
+	return client.downloadURL(path, sid, synoToken)
+}
+
+// downloadURL get the URL to download file in the session sid
+func (client *Client) downloadURL(path string, sid, synoToken string) (string, error) {
 	sharedFolder := client.Config.SharedFolder
 	baseURL := client.Config.Endpoint + "/webapi/entry.cgi"
 	path = filepath.ToSlash(path)
@@ -487,12 +800,10 @@ func (client Client) GetURL(path string) (get_url string, err error) {
 	params.Set("api", apiName)
 	params.Set("version", "2")
 	params.Set("method", "download")
-	params.Set("path", sharedFolder + path)
+	params.Set("path", sharedFolder+path)
 	params.Set("mode", "download")
-	params.Set("SynoToken", client.SynoToken)
-	params.Set("_sid", client.SID)
-
-	get_url = baseURL + "?" + params.Encode()
+	params.Set("SynoToken", synoToken)
+	params.Set("_sid", sid)
 
-	return get_url, nil
+	return baseURL + "?" + params.Encode(), nil
 }
@@ -15,8 +15,17 @@
 package synology_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/casdoor/oss"
 	"github.com/casdoor/oss/synology"
 	"github.com/casdoor/oss/tests"
 	"github.com/jinzhu/configor"
@@ -70,3 +79,328 @@ func TestAll(t *testing.T) {
 		tests.TestAll(cli, t)
 	}
 }
+
+func TestSessionRefresh(t *testing.T) {
+	dsm := newFakeDSM(t)
+	client := synology.New(&synology.Config{AccessID: "admin", AccessKey: "password", Endpoint: dsm.URL, SharedFolder: "/share"})
+
+	if _, err := client.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Fatalf("No error should happen when put file, but got %v", err)
+	}
+
+	// the session is refreshed transparently
+	dsm.Expire()
+	if stream, err := client.GetStream("/sample.txt"); err != nil {
+		t.Errorf("No error should happen when get file with expired session, but got %v", err)
+	} else if content, _ := ioutil.ReadAll(stream); string(content) != "sample" {
+		t.Errorf("File should be sample, but got %v", string(content))
+	}
+	dsm.Expire()
+	if _, err := client.Put("/sample2.txt", strings.NewReader("sample2")); err != nil {
+		t.Errorf("No error should happen when put file with expired session, but got %v", err)
+	}
+	if dsm.Logins() != 3 || client.Config.SessionExpire {
+		t.Errorf("Client should log in again for every expired session, but got %v logins", dsm.Logins())
+	}
+
+	// concurrent requests log in only once
+	dsm.Expire()
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			if _, err := client.Stat("/sample.txt"); err != nil {
+				t.Errorf("No error should happen when stat file concurrently, but got %v", err)
+			}
+		}()
+	}
+	waitGroup.Wait()
+	if dsm.Logins() != 4 {
+		t.Errorf("Client should log in once for concurrent requests, but got %v logins", dsm.Logins())
+	}
+
+	if err := client.Logout(); err != nil {
+		t.Errorf("No error should happen when log out, but got %v", err)
+	}
+	if dsm.Sessions() != 0 || client.SID != "" {
+		t.Errorf("Session should be logged out, but got %v sessions", dsm.Sessions())
+	}
+
+	// wrong password isn't retried forever
+	client = synology.New(&synology.Config{AccessID: "admin", AccessKey: "wrong", Endpoint: dsm.URL, SharedFolder: "/share"})
+	if _, err := client.Stat("/sample.txt"); !errors.Is(err, oss.ErrPermission) {
+		t.Errorf("Error should be ErrPermission when the password is wrong, but got %v", err)
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	dsm := newFakeDSM(t)
+	if client, err := synology.NewWithError(&synology.Config{AccessID: "admin", AccessKey: "password", Endpoint: dsm.URL, SharedFolder: "/share"}); err != nil || client.SID == "" {
+		t.Errorf("Client should be logged in, but got %v", err)
+	}
+	if _, err := synology.NewWithError(&synology.Config{AccessID: "admin", AccessKey: "wrong", Endpoint: dsm.URL, SharedFolder: "/share"}); !errors.Is(err, oss.ErrPermission) {
+		t.Errorf("Error should be ErrPermission when the password is wrong, but got %v", err)
+	}
+}
+
+func TestAllWithFakeDSM(t *testing.T) {
+	dsm := newFakeDSM(t)
+	tests.TestAll(synology.New(&synology.Config{AccessID: "admin", AccessKey: "password", Endpoint: dsm.URL, SharedFolder: "/share"}), t)
+}
+
+func TestList(t *testing.T) {
+	dsm := newFakeDSM(t)
+	client := synology.New(&synology.Config{AccessID: "admin", AccessKey: "password", Endpoint: dsm.URL, SharedFolder: "/share"})
+
+	// more files than a page
+	for i := 0; i < 1005; i++ {
+		dsm.files[fmt.Sprintf("/share/dir/%04d.txt", i)] = &fakeFile{content: []byte("sample"), mtime: 1700000000}
+	}
+	dsm.files["/share/dir/sub/deep/sample.txt"] = &fakeFile{content: []byte("sample"), mtime: 1700000000}
+	dsm.files["/share/other.txt"] = &fakeFile{content: []byte("sample"), mtime: 1700000000}
+
+	objects, err := client.List("/dir")
+	if err != nil {
+		t.Fatalf("No error should happen when list files, but got %v", err)
+	}
+	if len(objects) != 1006 {
+		t.Errorf("Should list all files in pages and subfolders, but got %v", len(objects))
+	}
+	for _, object := range objects {
+		if object.Path == "/dir/sub" || object.Path == "/dir/sub/deep" {
+			t.Errorf("Folders shouldn't be listed, but got %v", object.Path)
+		}
+	}
+	if object := objects[len(objects)-1]; object.Path != "/dir/sub/deep/sample.txt" || object.Name != "sample.txt" ||
+		object.Size != 6 || object.LastModified == nil || object.LastModified.Unix() != 1700000000 {
+		t.Errorf("Files should be listed with metadata, but got %+v", object)
+	}
+
+	if objects, err := client.List("/missing"); err != nil || len(objects) != 0 {
+		t.Errorf("Nothing should be listed in missing folder, but got %v %v", objects, err)
+	}
+
+	var walked int
+	if err := client.Walk("/dir", func(object *oss.Object) error { walked++; return oss.SkipAll }); err != nil || walked != 1 {
+		t.Errorf("Walk should stop without an error after SkipAll, but got %v after %v files", err, walked)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	dsm := newFakeDSM(t)
+	client := synology.New(&synology.Config{AccessID: "admin", AccessKey: "password", Endpoint: dsm.URL, SharedFolder: "/share"})
+	for _, name := range []string{"/a.txt", "/b.txt", "/dir/c.txt", "/d.txt"} {
+		if _, err := client.Put(name, strings.NewReader("sample")); err != nil {
+			t.Fatalf("No error should happen when put file, but got %v", err)
+		}
+	}
+
+	// Delete waits for the task
+	dsm.deletePolls = 1
+	if err := client.Delete("/a.txt"); err != nil {
+		t.Errorf("No error should happen when delete file, but got %v", err)
+	} else if _, err := client.Stat("/a.txt"); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("File should be deleted when Delete returns, but got %v", err)
+	}
+	if err := client.Delete("/a.txt"); !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("Error should be ErrNotFound when delete missing file, but got %v", err)
+	}
+
+	// deleting in the background
+	task, err := client.StartDelete("/b.txt", "/dir")
+	if err != nil {
+		t.Fatalf("No error should happen when start deleting files, but got %v", err)
+	}
+	if _, ok := dsm.files["/share/b.txt"]; !ok {
+		t.Errorf("Files shouldn't be deleted before the task is checked")
+	}
+	if finished, err := task.Status(); err != nil || !finished {
+		t.Errorf("Task should be finished, but got %v %v", finished, err)
+	}
+	if objects, _ := client.List("/"); len(objects) != 1 || objects[0].Path != "/d.txt" {
+		t.Errorf("Only d.txt should be left, but got %v", objects)
+	}
+
+	// the task keeps running after the timeout
+	client.Config.TaskTimeout = 10 * time.Millisecond
+	dsm.deletePolls = 1000
+	if err := client.Delete("/d.txt"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Error should be DeadlineExceeded when the task is too slow, but got %v", err)
+	}
+}
+
+func TestCopyMove(t *testing.T) {
+	dsm := newFakeDSM(t)
+	client := synology.New(&synology.Config{AccessID: "admin", AccessKey: "password", Endpoint: dsm.URL, SharedFolder: "/share"})
+	for name, content := range map[string]string{"/a/x": "ax", "/b/x": "bx", "/c/y": "cy"} {
+		dsm.files["/share"+name] = &fakeFile{content: []byte(content), mtime: 1700000000}
+	}
+	content := func(name string) string {
+		if file, ok := dsm.files["/share"+name]; ok {
+			return string(file.content)
+		}
+		return ""
+	}
+
+	// in the same folder
+	if err := client.Copy("/a/x", "/a/y"); err != nil {
+		t.Errorf("No error should happen when copy file in the same folder, but got %v", err)
+	}
+	if content("/a/x") != "ax" || content("/a/y") != "ax" {
+		t.Errorf("Source should be kept after copying in the same folder, but got %q %q", content("/a/x"), content("/a/y"))
+	}
+
+	// the name of the source exists in the destination folder
+	if err := client.Move("/a/y", "/b/y"); err != nil {
+		t.Errorf("No error should happen when move file, but got %v", err)
+	}
+	if content("/b/x") != "bx" || content("/b/y") != "ax" || content("/a/y") != "" {
+		t.Errorf("Other files shouldn't be touched when move file, but got %q %q %q", content("/b/x"), content("/b/y"), content("/a/y"))
+	}
+	if err := client.Copy("/a/x", "/b/y"); err != nil || content("/b/x") != "bx" {
+		t.Errorf("Other files shouldn't be touched when copy file, but got %v %q", err, content("/b/x"))
+	}
+
+	// overwriting
+	if err := client.Copy("/c/y", "/b/y"); err != nil || content("/b/y") != "cy" || content("/c/y") != "cy" {
+		t.Errorf("Destination should be overwritten, but got %v %q", err, content("/b/y"))
+	}
+	if err := client.Copy("/b/y", "/b/y"); err != nil || content("/b/y") != "cy" {
+		t.Errorf("Copying file to itself should do nothing, but got %v %q", err, content("/b/y"))
+	}
+
+	for name := range dsm.files {
+		if strings.Contains(name, ".oss-copy") {
+			t.Errorf("Temporary files should be deleted, but got %v", name)
+		}
+	}
+	for name := range dsm.folders {
+		if strings.Contains(name, ".oss-copy") {
+			t.Errorf("Temporary folders should be deleted, but got %v", name)
+		}
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	dsm := newFakeDSM(t)
+	client := synology.New(&synology.Config{AccessID: "admin", AccessKey: "wrong", Endpoint: dsm.URL, SharedFolder: "/share"})
+
+	var apiErr *synology.APIError
+	err := client.Login("FileStation")
+	if !errors.As(err, &apiErr) || apiErr.API != "SYNO.API.Auth" || apiErr.Code != 400 || !errors.Is(err, oss.ErrPermission) {
+		t.Fatalf("Error should be APIError 400 of SYNO.API.Auth when login with wrong password, but got %v", err)
+	}
+	if apiErr.Message() != "No such account or incorrect password" {
+		t.Errorf("Message of login error is wrong, got %v", apiErr.Message())
+	}
+
+	client.Config.AccessKey = "password"
+	if err := client.Login("FileStation"); err != nil {
+		t.Fatalf("No error should happen when login, but got %v", err)
+	}
+	for name, call := range map[string]func() error{
+		"Stat":      func() error { _, err := client.Stat("/missing.txt"); return err },
+		"GetStream": func() error { _, err := client.GetStream("/missing.txt"); return err },
+		"Delete":    func() error { return client.Delete("/missing.txt") },
+		"Move":      func() error { return client.Move("/missing.txt", "/moved.txt") },
+	} {
+		err := call()
+		if !errors.As(err, &apiErr) || apiErr.Code != 408 || !errors.Is(err, oss.ErrNotFound) {
+			t.Errorf("Error of %v should be APIError 408, but got %v", name, err)
+		} else if !strings.Contains(err.Error(), "No such file or directory") {
+			t.Errorf("Error of %v should contain the message, but got %v", name, err)
+		}
+	}
+
+	quota := &synology.APIError{API: "SYNO.FileStation.Upload", Code: 415, Path: "/share/a.txt"}
+	if quota.Error() != "synology: SYNO.FileStation.Upload failed for /share/a.txt: Disk quota exceeded (error code 415)" {
+		t.Errorf("Error message is wrong, got %v", quota.Error())
+	}
+}
+
+// onlyReader hide methods of the reader other than Read, like Seek and Len
+type onlyReader struct {
+	io.Reader
+}
+
+// failingReader fail reading after the content
+type failingReader struct {
+	content io.Reader
+	err     error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if n, err := r.content.Read(p); err != io.EOF {
+		return n, err
+	}
+	return 0, r.err
+}
+
+func TestUpload(t *testing.T) {
+	dsm := newFakeDSM(t)
+	client := synology.New(&synology.Config{AccessID: "admin", AccessKey: "password", Endpoint: dsm.URL, SharedFolder: "/share"})
+
+	// the size of a strings.Reader is known, the body is sent with Content-Length
+	if object, err := client.Put("/a.txt", strings.NewReader("sample")); err != nil || object.Size != 6 {
+		t.Fatalf("No error should happen when put file, but got %v %v", object, err)
+	}
+	if length := dsm.uploadLengths[len(dsm.uploadLengths)-1]; length <= 6 {
+		t.Errorf("Upload should be sent with Content-Length, but got %v", length)
+	}
+
+	// a reader of unknown size is streamed in chunks
+	content := strings.Repeat("0123456789", 300000)
+	if _, err := client.Put("/large.txt", onlyReader{strings.NewReader(content)}); err != nil {
+		t.Fatalf("No error should happen when put file, but got %v", err)
+	}
+	if length := dsm.uploadLengths[len(dsm.uploadLengths)-1]; length != -1 {
+		t.Errorf("Upload should be chunked, but got Content-Length %v", length)
+	}
+	if string(dsm.files["/share/large.txt"].content) != content {
+		t.Errorf("Content of the streamed file is wrong")
+	}
+
+	// errors of reading are returned
+	readErr := errors.New("read failed")
+	if _, err := client.Put("/failed.txt", &failingReader{content: strings.NewReader("sample"), err: readErr}); !errors.Is(err, readErr) {
+		t.Errorf("Error of reading should be returned, but got %v", err)
+	}
+
+	// modification and creation time
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	createTime := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := client.Upload("/dir/b.txt", strings.NewReader("sample"), &synology.UploadOptions{ModTime: modTime, CreateTime: createTime}); err != nil {
+		t.Fatalf("No error should happen when upload file, but got %v", err)
+	}
+	if file := dsm.files["/share/dir/b.txt"]; file.mtime != modTime.Unix() || file.crtime != createTime.Unix() {
+		t.Errorf("Times of the file are wrong, got %v %v", file.mtime, file.crtime)
+	}
+	if object, err := client.Stat("/dir/b.txt"); err != nil || !object.LastModified.Equal(modTime) {
+		t.Errorf("LastModified should be the modification time, but got %v %v", object, err)
+	}
+
+	// overwriting
+	client.Config.DisableOverwrite = true
+	var apiErr *synology.APIError
+	if _, err := client.Put("/a.txt", strings.NewReader("new")); !errors.Is(err, oss.ErrAlreadyExists) || !errors.As(err, &apiErr) || apiErr.Code != 414 {
+		t.Errorf("Error should be ErrAlreadyExists when overwriting is disabled, but got %v", err)
+	}
+	client.Config.DisableOverwrite = false
+	if _, err := client.Put("/a.txt", strings.NewReader("new")); err != nil || string(dsm.files["/share/a.txt"].content) != "new" {
+		t.Errorf("File should be overwritten, but got %v", err)
+	}
+
+	// a seekable reader is uploaded again after the session is refreshed
+	dsm.Expire()
+	if _, err := client.Put("/c.txt", strings.NewReader("sample")); err != nil || string(dsm.files["/share/c.txt"].content) != "sample" {
+		t.Errorf("File should be uploaded after the session is refreshed, but got %v", err)
+	}
+	dsm.Expire()
+	if _, err := client.Put("/d.txt", onlyReader{strings.NewReader("sample")}); !errors.Is(err, oss.ErrPermission) {
+		t.Errorf("Error should be ErrPermission when the reader couldn't be read again, but got %v", err)
+	}
+	if _, err := client.Put("/d.txt", onlyReader{strings.NewReader("sample")}); err != nil {
+		t.Errorf("No error should happen after the session is refreshed, but got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package synology
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// DeleteTask a background task of SYNO.FileStation.Delete deleting files
+type DeleteTask struct {
+	TaskID string
+	client *Client
+}
+
+// StartDelete start deleting files or folders in the background, which doesn't wait for the deletion
+func (client *Client) StartDelete(paths ...string) (*DeleteTask, error) {
+	return client.StartDeleteContext(context.Background(), paths...)
+}
+
+// StartDeleteContext start deleting files or folders in the background, which doesn't wait for the deletion,
+// folders are deleted recursively
+func (client *Client) StartDeleteContext(ctx context.Context, paths ...string) (*DeleteTask, error) {
+	var fullPaths []string
+	for _, path := range paths {
+		fullPaths = append(fullPaths, client.Config.SharedFolder+filepath.ToSlash(path))
+	}
+	pathsJSON, err := json.Marshal(fullPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("api", "SYNO.FileStation.Delete")
+	params.Set("version", "2")
+	params.Set("method", "start")
+	params.Set("path", string(pathsJSON))
+	params.Set("recursive", "true")
+
+	var task struct {
+		TaskID string `json:"taskid"`
+	}
+	if err := client.callAPI(ctx, params, &task); err != nil {
+		return nil, err
+	}
+	return &DeleteTask{TaskID: task.TaskID, client: client}, nil
+}
+
+// Status check whether the deletion is finished
+func (task *DeleteTask) Status() (finished bool, err error) {
+	return task.StatusContext(context.Background())
+}
+
+// StatusContext check whether the deletion is finished, the error of the deletion is returned if it fails
+func (task *DeleteTask) StatusContext(ctx context.Context) (finished bool, err error) {
+	return task.client.taskStatus(ctx, "SYNO.FileStation.Delete", "2", task.TaskID)
+}
+
+// Wait wait for the deletion to finish
+func (task *DeleteTask) Wait() error {
+	return task.WaitContext(context.Background())
+}
+
+// WaitContext wait for the deletion to finish, at most Config.TaskTimeout
+func (task *DeleteTask) WaitContext(ctx context.Context) error {
+	return task.client.waitTask(ctx, "SYNO.FileStation.Delete", "2", task.TaskID)
+}
+
+// taskStatus check whether a background task of api is finished
+func (client *Client) taskStatus(ctx context.Context, api, version, taskID string) (bool, error) {
+	params := url.Values{}
+	params.Set("api", api)
+	params.Set("version", version)
+	params.Set("method", "status")
+	params.Set("taskid", taskID)
+
+	var status struct {
+		Finished bool `json:"finished"`
+	}
+	if err := client.callAPI(ctx, params, &status); err != nil {
+		return false, err
+	}
+	return status.Finished, nil
+}
+
+// waitTask poll the status of a background task of api until it's finished, or Config.TaskTimeout is exceeded,
+// the task keeps running in DSM after the timeout
+func (client *Client) waitTask(ctx context.Context, api, version, taskID string) error {
+	timeout := client.Config.TaskTimeout
+	if timeout <= 0 {
+		timeout = defaultTaskTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		finished, err := client.taskStatus(ctx, api, version, taskID)
+		if err != nil || finished {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("%s task %s isn't finished in %v: %w", api, taskID, timeout, context.DeadlineExceeded)
+		case <-time.After(taskPollInterval):
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package synology
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casdoor/oss"
+)
+
+// UploadOptions options of uploading a file with FileStation
+type UploadOptions struct {
+	// ModTime modification time of the file, DSM uses the time of uploading if it's zero
+	ModTime time.Time
+	// CreateTime creation time of the file, DSM uses the time of uploading if it's zero
+	CreateTime time.Time
+}
+
+// Upload store a reader into given path with options, options could be nil
+func (client *Client) Upload(urlPath string, reader io.Reader, options *UploadOptions) (*oss.Object, error) {
+	return client.UploadContext(context.Background(), urlPath, reader, options)
+}
+
+// UploadContext store a reader into given path with options, options could be nil.
+// The multipart body is streamed from reader, which is uploaded again after the session is refreshed only if it's an io.Seeker
+func (client *Client) UploadContext(ctx context.Context, urlPath string, reader io.Reader, options *UploadOptions) (*oss.Object, error) {
+	if options == nil {
+		options = &UploadOptions{}
+	}
+	parsedURL, err := url.Parse(urlPath)
+	if err != nil {
+		return nil, err
+	}
+	// change windows path to linux path
+	dir := filepath.ToSlash(filepath.Dir(parsedURL.Path))
+	filename := filepath.Base(urlPath)
+
+	// the file must be the last part of the form for FileStation, so fields are kept in order
+	fields := [][2]string{{"path", client.Config.SharedFolder + dir}, {"create_parents", "true"}}
+	if !client.Config.DisableOverwrite {
+		fields = append(fields, [2]string{"overwrite", "true"})
+	}
+	if !options.ModTime.IsZero() {
+		fields = append(fields, [2]string{"mtime", strconv.FormatInt(options.ModTime.UnixMilli(), 10)})
+	}
+	if !options.CreateTime.IsZero() {
+		fields = append(fields, [2]string{"crtime", strconv.FormatInt(options.CreateTime.UnixMilli(), 10)})
+	}
+
+	form := multipart.NewWriter(io.Discard)
+	boundary := form.Boundary()
+
+	// Content-Length is sent if the size of reader is known, otherwise the body is chunked
+	contentLength := int64(-1)
+	if size, ok := readerSize(reader); ok {
+		counter := &countingWriter{}
+		writer := multipart.NewWriter(counter)
+		if err := writer.SetBoundary(boundary); err != nil {
+			return nil, err
+		}
+		if _, err := writeForm(writer, fields, filename, strings.NewReader("")); err != nil {
+			return nil, err
+		}
+		contentLength = counter.n + size
+	}
+
+	seeker, _ := reader.(io.Seeker)
+	var start int64
+	if seeker != nil {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			seeker = nil
+		}
+	}
+
+	params := url.Values{}
+	params.Set("api", "SYNO.FileStation.Upload")
+	params.Set("version", "2")
+	params.Set("method", "upload")
+
+	var (
+		body     *io.PipeReader
+		done     chan struct{}
+		written  int64
+		writeErr error
+	)
+	// finish stop writing the body of the last request, reader isn't read any more after it returns
+	finish := func() {
+		if body != nil {
+			body.Close()
+			<-done
+		}
+	}
+	err = client.do(ctx, func(sid, synoToken string) (*http.Request, error) {
+		if body != nil {
+			finish()
+			if seeker == nil {
+				return nil, oss.NewError(oss.ErrPermission, errors.New("session expired during upload, and the reader couldn't be read again"))
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+
+		var pipeWriter *io.PipeWriter
+		body, pipeWriter = io.Pipe()
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			writer := multipart.NewWriter(pipeWriter)
+			writeErr = writer.SetBoundary(boundary)
+			if writeErr == nil {
+				written, writeErr = writeForm(writer, fields, filename, reader)
+			}
+			pipeWriter.CloseWithError(writeErr)
+		}()
+
+		params.Set("SynoToken", synoToken)
+		req, err := client.newRequest(ctx, "POST", client.Config.Endpoint+"/webapi/entry.cgi?"+params.Encode(), body, sid, synoToken)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		if contentLength >= 0 {
+			req.ContentLength = contentLength
+		}
+		return req, nil
+	}, nil)
+	finish()
+	if err == nil && writeErr != nil {
+		err = writeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lastModified := options.ModTime
+	if lastModified.IsZero() {
+		lastModified = time.Now()
+	}
+	return &oss.Object{
+		Path:             urlPath,
+		Name:             filename,
+		LastModified:     &lastModified,
+		Size:             written,
+		StorageInterface: client,
+	}, nil
+}
+
+// writeForm write fields and the file of an upload into writer, and return the size of the file
+func writeForm(writer *multipart.Writer, fields [][2]string, filename string, reader io.Reader) (int64, error) {
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return 0, err
+		}
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(part, reader)
+	if err != nil {
+		return written, err
+	}
+	return written, writer.Close()
+}
+
+// readerSize get the count of bytes left in reader if it's known without reading
+func readerSize(reader io.Reader) (int64, bool) {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case io.Seeker:
+		current, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := r.Seek(current, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - current, true
+	}
+	return 0, false
+}
+
+// countingWriter count bytes written into it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
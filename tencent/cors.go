@@ -0,0 +1,82 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tencent
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"strings"
+)
+
+// corsMaxAge seconds browsers could cache the result of preflight requests
+const corsMaxAge = 600
+
+type corsConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration"`
+	Rules   []corsRule `xml:"CORSRule"`
+}
+
+type corsRule struct {
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader"`
+	ExposeHeaders  []string `xml:"ExposeHeader"`
+	MaxAgeSeconds  int
+}
+
+// PutBucketCORS replace the CORS rules of the bucket with a rule allowing origins of Config.CORS,
+// which isn't called by New, so call it once after the origins are changed
+func (client Client) PutBucketCORS() error {
+	return client.PutBucketCORSContext(context.Background())
+}
+
+// PutBucketCORSContext replace the CORS rules of the bucket with COS API `PUT /?cors`, which allow origins of Config.CORS
+// to read and upload objects, e.g. with URLs from GetURL and GetUploadURL
+func (client Client) PutBucketCORSContext(ctx context.Context) error {
+	var origins []string
+	for _, origin := range strings.Split(client.Config.CORS, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return errors.New("no CORS origin is configured")
+	}
+
+	body, err := xml.Marshal(corsConfiguration{Rules: []corsRule{{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{"GET", "HEAD", "PUT", "POST", "DELETE"},
+		AllowedHeaders: []string{"*"},
+		ExposeHeaders:  []string{"ETag", "Content-Length", "x-cos-request-id"},
+		MaxAgeSeconds:  corsMaxAge,
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := client.newRequest(ctx, "PUT", "", "cors", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	sum := md5.Sum(body)
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	_, err = client.doRequest(req, nil)
+	return err
+}
@@ -0,0 +1,286 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tencent
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casdoor/oss"
+	"github.com/casdoor/oss/internal/s3multipart"
+)
+
+const (
+	// defaultPartSize size of parts uploaded by Put if Config.PartSize isn't specified
+	defaultPartSize = 8 << 20
+	// defaultConcurrency count of parts uploaded concurrently by Put if Config.Concurrency isn't specified
+	defaultConcurrency = 4
+)
+
+// InitiateUpload initiate a multipart upload with COS API `POST /<ObjectKey>?uploads`, the object is uploaded with Config.ACL
+func (client Client) InitiateUpload(path string) (string, error) {
+	return client.InitiateUploadContext(context.Background(), path)
+}
+
+// InitiateUploadContext initiate a multipart upload with COS API `POST /<ObjectKey>?uploads`, the object is uploaded with Config.ACL
+func (client Client) InitiateUploadContext(ctx context.Context, path string) (string, error) {
+	return client.initiateUpload(ctx, path, nil)
+}
+
+// initiateUpload initiate a multipart upload of the object with options
+func (client Client) initiateUpload(ctx context.Context, path string, options *oss.PutOptions) (string, error) {
+	req, err := client.newRequest(ctx, "POST", path, "uploads", nil)
+	if err != nil {
+		return "", err
+	}
+	client.setPutHeaders(req.Header, options)
+
+	var result s3multipart.InitiateResult
+	if _, err := client.doRequest(req, &result); err != nil {
+		return "", err
+	}
+	return result.UploadID, nil
+}
+
+// UploadPart upload a part of a multipart upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPart(path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	return client.UploadPartContext(context.Background(), path, uploadID, number, reader)
+}
+
+// UploadPartContext upload a part of a multipart upload, reader is read into memory if it isn't an io.ReadSeeker
+func (client Client) UploadPartContext(ctx context.Context, path string, uploadID string, number int, reader io.Reader) (*oss.Part, error) {
+	body, size, err := s3multipart.PartBody(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("partNumber", strconv.Itoa(number))
+	query.Set("uploadId", uploadID)
+	req, err := client.newRequest(ctx, "PUT", path, query.Encode(), ioutil.NopCloser(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+
+	header, err := client.doRequest(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &oss.Part{Number: number, ETag: strings.Trim(header.Get("ETag"), `"`), Size: size}, nil
+}
+
+// ListParts list uploaded parts of a multipart upload with COS API `GET /<ObjectKey>?uploadId=UploadId`
+func (client Client) ListParts(path string, uploadID string) ([]*oss.Part, error) {
+	return client.ListPartsContext(context.Background(), path, uploadID)
+}
+
+// ListPartsContext list uploaded parts of a multipart upload with COS API `GET /<ObjectKey>?uploadId=UploadId`
+func (client Client) ListPartsContext(ctx context.Context, path string, uploadID string) ([]*oss.Part, error) {
+	var (
+		parts  []*oss.Part
+		marker int
+	)
+
+	for {
+		query := url.Values{}
+		query.Set("uploadId", uploadID)
+		if marker > 0 {
+			query.Set("part-number-marker", strconv.Itoa(marker))
+		}
+		req, err := client.newRequest(ctx, "GET", path, query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3multipart.ListPartsResult
+		if _, err := client.doRequest(req, &result); err != nil {
+			return nil, err
+		}
+		parts = append(parts, result.OSSParts()...)
+
+		if !result.IsTruncated {
+			return parts, nil
+		}
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// CompleteUpload combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUpload(path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	return client.CompleteUploadContext(context.Background(), path, uploadID, parts)
+}
+
+// CompleteUploadContext combine parts into the object, all uploaded parts are combined if parts is nil
+func (client Client) CompleteUploadContext(ctx context.Context, path string, uploadID string, parts []*oss.Part) (*oss.Object, error) {
+	if parts == nil {
+		var err error
+		if parts, err = client.ListPartsContext(ctx, path, uploadID); err != nil {
+			return nil, err
+		}
+	}
+	body, size, err := s3multipart.CompleteBody(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := client.newRequest(ctx, "POST", path, url.Values{"uploadId": {uploadID}}.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	var result s3multipart.CompleteResult
+	if _, err := client.doRequest(req, &result); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &oss.Object{
+		Path:             path,
+		Name:             filepath.Base(path),
+		LastModified:     &now,
+		Size:             size,
+		ETag:             strings.Trim(result.ETag, `"`),
+		StorageInterface: client,
+	}, nil
+}
+
+// AbortUpload abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUpload(path string, uploadID string) error {
+	return client.AbortUploadContext(context.Background(), path, uploadID)
+}
+
+// AbortUploadContext abort a multipart upload and delete its uploaded parts
+func (client Client) AbortUploadContext(ctx context.Context, path string, uploadID string) error {
+	req, err := client.newRequest(ctx, "DELETE", path, url.Values{"uploadId": {uploadID}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.doRequest(req, nil)
+	return err
+}
+
+// putMultipart upload body in parts of Config.PartSize, Config.Concurrency parts are uploaded concurrently
+// while the next part is read, the upload is aborted if any part fails
+func (client Client) putMultipart(ctx context.Context, path string, body io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	uploadID, err := client.initiateUpload(ctx, path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		waitGroup sync.WaitGroup
+		mutex     sync.Mutex
+		parts     []*oss.Part
+		firstErr  error
+		partSize  = client.partSize()
+		// buffers of parts, which are allocated on first use, a part is read only when a buffer is free
+		buffers = make(chan []byte, client.concurrency())
+	)
+	for i := 0; i < cap(buffers); i++ {
+		buffers <- nil
+	}
+	fail := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+read:
+	for number := 1; ; number++ {
+		var buffer []byte
+		select {
+		case buffer = <-buffers:
+		case <-uploadCtx.Done():
+			break read
+		}
+		if buffer == nil {
+			buffer = make([]byte, partSize)
+		}
+
+		n, err := io.ReadFull(body, buffer)
+		if err == io.EOF && number > 1 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			fail(err)
+			break
+		}
+
+		waitGroup.Add(1)
+		go func(number int, buffer []byte, n int) {
+			defer waitGroup.Done()
+			defer func() { buffers <- buffer }()
+
+			part, err := client.UploadPartContext(uploadCtx, path, uploadID, number, bytes.NewReader(buffer[:n]))
+			if err != nil {
+				fail(err)
+				return
+			}
+			mutex.Lock()
+			parts = append(parts, part)
+			mutex.Unlock()
+		}(number, buffer, n)
+
+		if n < len(buffer) {
+			break
+		}
+	}
+	waitGroup.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr == nil {
+		object, err := client.CompleteUploadContext(ctx, path, uploadID, parts)
+		if err == nil {
+			return object, nil
+		}
+		firstErr = err
+	}
+
+	// parts are deleted even if the context is canceled
+	client.AbortUploadContext(context.Background(), path, uploadID)
+	return nil, firstErr
+}
+
+func (client Client) partSize() int64 {
+	if client.Config.PartSize > 0 {
+		return client.Config.PartSize
+	}
+	return defaultPartSize
+}
+
+func (client Client) concurrency() int {
+	if client.Config.Concurrency > 0 {
+		return client.Config.Concurrency
+	}
+	return defaultConcurrency
+}
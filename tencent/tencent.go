@@ -16,6 +16,8 @@ package tencent
 
 import (
 	"bytes"
+	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
@@ -25,13 +27,24 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/casdoor/oss"
 )
 
-var _ oss.StorageInterface = (*Client)(nil)
+var (
+	_ oss.StorageInterface  = (*Client)(nil)
+	_ oss.ContextStorage    = (*Client)(nil)
+	_ oss.Stater            = (*Client)(nil)
+	_ oss.OptionPutter      = (*Client)(nil)
+	_ oss.RangeGetter       = (*Client)(nil)
+	_ oss.UploadURLGetter   = (*Client)(nil)
+	_ oss.URLOptionGetter   = (*Client)(nil)
+	_ oss.MultipartUploader = (*Client)(nil)
+	_ oss.OptionLister      = (*Client)(nil)
+)
 
 type Config struct {
 	AppID     string
@@ -39,9 +52,27 @@ type Config struct {
 	AccessKey string
 	Region    string
 	Bucket    string
-	ACL       string
-	CORS      string
-	Endpoint  string
+	// ACL default ACL of uploaded objects, private, public-read or public-read-write, which is overridden by PutOptions.ACL.
+	// GetURL returns presigned URLs if it's private, and plain URLs otherwise, e.g. if it's empty and objects inherit the ACL of the bucket
+	ACL string
+	// CORS origins allowed to access the bucket from browsers, separated by commas. They aren't applied by New,
+	// call PutBucketCORS once to replace the CORS rules of the bucket with them
+	CORS string
+	// Endpoint custom domain of URLs returned by GetURL, such as a CDN domain with the bucket as its origin,
+	// e.g. cdn.example.com or https://cdn.example.com. API requests are always sent to the COS domain of the bucket
+	Endpoint string
+	// URLExpiry expiry of presigned URLs returned by GetURL, oss.DefaultURLExpiry by default
+	URLExpiry time.Duration
+	// Accelerate send requests to the global acceleration domain <bucket>.cos.accelerate.myqcloud.com,
+	// which has to be enabled for the bucket
+	Accelerate bool
+	// DisableHTTPS send requests and get URLs with plain http instead of https
+	DisableHTTPS bool
+	// PartSize size of each part of multipart uploads, objects larger than it are uploaded in parts by Put,
+	// defaultPartSize (8 MB) if zero. COS requires parts except the last one to be at least 1 MB
+	PartSize int64
+	// Concurrency count of parts uploaded concurrently by Put, defaultConcurrency if zero
+	Concurrency int
 }
 
 type Client struct {
@@ -53,12 +84,44 @@ func New(conf *Config) *Client {
 	return &Client{conf, &http.Client{}}
 }
 
+// getUrl get the base URL of API requests, which is the COS domain of the bucket
 func (client Client) getUrl() string {
-	return fmt.Sprintf("http://%s.cos.%s.myqcloud.com/", client.Config.Bucket, client.Config.Region)
+	return fmt.Sprintf("%s://%s/", client.scheme(), client.originHost())
+}
+
+// originHost get the COS domain of the bucket, or its global acceleration domain if Config.Accelerate is true
+func (client Client) originHost() string {
+	if client.Config.Accelerate {
+		return fmt.Sprintf("%s.cos.accelerate.myqcloud.com", client.Config.Bucket)
+	}
+	return fmt.Sprintf("%s.cos.%s.myqcloud.com", client.Config.Bucket, client.Config.Region)
+}
+
+// publicUrl get the base URL of URLs returned by GetURL, which is Config.Endpoint if it's specified
+func (client Client) publicUrl() string {
+	endpoint := strings.TrimSuffix(client.Config.Endpoint, "/")
+	if endpoint == "" {
+		return client.getUrl()
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = client.scheme() + "://" + endpoint
+	}
+	return endpoint + "/"
+}
+
+func (client Client) scheme() string {
+	if client.Config.DisableHTTPS {
+		return "http"
+	}
+	return "https"
 }
 
 func (client Client) Get(path string) (file *os.File, err error) {
-	readCloser, err := client.GetStream(path)
+	return client.GetContext(context.Background(), path)
+}
+
+func (client Client) GetContext(ctx context.Context, path string) (file *os.File, err error) {
+	readCloser, err := client.GetStreamContext(ctx, path)
 	if err == nil {
 		if file, err = ioutil.TempFile("/tmp", "tencent"); err == nil {
 			defer readCloser.Close()
@@ -83,113 +146,450 @@ func (client Client) ToRelativePath(urlPath string) string {
 }
 
 func (client Client) GetStream(path string) (io.ReadCloser, error) {
-	resp, err := http.Get(fmt.Sprintf("%s%s", client.getUrl(), client.ToRelativePath(path)))
+	return client.GetStreamContext(context.Background(), path)
+}
+
+func (client Client) GetStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	resp, err := client.getStream(ctx, path, "")
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("get file fail")
-	}
 	return resp.Body, nil
 }
 
+func (client Client) GetRange(path string, offset, length int64) (io.ReadCloser, error) {
+	return client.GetRangeContext(context.Background(), path, offset, length)
+}
+
+func (client Client) GetRangeContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	resp, err := client.getStream(ctx, path, oss.HTTPRange(offset, length))
+	if err != nil {
+		return nil, err
+	}
+	return oss.RangeBody(resp, offset, length)
+}
+
+// getStream get file as stream with a signed request, the whole file is requested if rangeHeader is empty
+func (client Client) getStream(ctx context.Context, path string, rangeHeader string) (*http.Response, error) {
+	req, err := client.newRequest(ctx, "GET", path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	req.Header.Set("Authorization", client.authorization(req))
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, statusError(resp.StatusCode, errors.New("get file fail"))
+	}
+	return resp, nil
+}
+
 func (client Client) Put(path string, body io.Reader) (*oss.Object, error) {
+	return client.PutContext(context.Background(), path, body)
+}
+
+func (client Client) PutContext(ctx context.Context, path string, body io.Reader) (*oss.Object, error) {
+	return client.PutWithOptionsContext(ctx, path, body, nil)
+}
+
+func (client Client) PutWithOptions(path string, body io.Reader, options *oss.PutOptions) (*oss.Object, error) {
+	return client.PutWithOptionsContext(context.Background(), path, body, options)
+}
+
+// PutWithOptionsContext upload the object with a single request if it isn't larger than Config.PartSize,
+// or a multipart upload otherwise, only a few parts are held in memory
+func (client Client) PutWithOptionsContext(ctx context.Context, path string, body io.Reader, options *oss.PutOptions) (*oss.Object, error) {
 	if seeker, ok := body.(io.ReadSeeker); ok {
 		seeker.Seek(0, 0)
 	}
-	switch body.(type) {
-	case *bytes.Buffer, *bytes.Reader, *strings.Reader:
-	default:
-		if body != nil {
-			b, err := ioutil.ReadAll(body)
-			if err != nil {
-				return nil, err
-			}
-			body = bytes.NewReader(b)
-		}
+	if body == nil {
+		body = bytes.NewReader(nil)
 	}
 
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s%s", client.getUrl(), client.ToRelativePath(path)), body)
+	partSize := client.partSize()
+	first, err := ioutil.ReadAll(io.LimitReader(body, partSize+1))
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Host", client.GetEndpoint())
+	if int64(len(first)) > partSize {
+		return client.putMultipart(ctx, path, io.MultiReader(bytes.NewReader(first), body), options)
+	}
+
+	req, err := client.newRequest(ctx, "PUT", path, "", bytes.NewReader(first))
+	if err != nil {
+		return nil, err
+	}
+	client.setPutHeaders(req.Header, options)
 	req.Header.Set("Authorization", client.authorization(req))
 	result, err := client.Client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer result.Body.Close()
 	if result.StatusCode != http.StatusOK {
-		d, err := ioutil.ReadAll(ioutil.NopCloser(result.Body))
+		d, err := ioutil.ReadAll(result.Body)
 		if err != nil {
 			return nil, err
 		}
-		return nil, errors.New(string(d))
+		return nil, statusError(result.StatusCode, errors.New(string(d)))
 	}
 	now := time.Now()
 	return &oss.Object{
 		Path:             path,
 		Name:             filepath.Base(path),
 		LastModified:     &now,
+		Size:             int64(len(first)),
 		StorageInterface: client,
 	}, nil
 }
 
 func (client Client) Delete(path string) error {
-	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s%s", client.getUrl(), client.ToRelativePath(path)), nil)
+	return client.DeleteContext(context.Background(), path)
+}
+
+func (client Client) DeleteContext(ctx context.Context, path string) error {
+	req, err := client.newRequest(ctx, "DELETE", path, "", nil)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Host", client.GetEndpoint())
 	req.Header.Set("Authorization", client.authorization(req))
 	result, err := client.Client.Do(req)
 	if err != nil {
 		return err
 	}
+	defer result.Body.Close()
 	if result.StatusCode != http.StatusOK && result.StatusCode != http.StatusNoContent {
-		d, err := ioutil.ReadAll(ioutil.NopCloser(result.Body))
+		d, err := ioutil.ReadAll(result.Body)
 		if err != nil {
 			return err
 		}
-		return errors.New(string(d))
+		return statusError(result.StatusCode, errors.New(string(d)))
 	}
 	return nil
 }
 
-// todo not found api
+func (client Client) Stat(path string) (*oss.Object, error) {
+	return client.StatContext(context.Background(), path)
+}
+
+func (client Client) StatContext(ctx context.Context, path string) (*oss.Object, error) {
+	key := client.ToRelativePath(path)
+	req, err := client.newRequest(ctx, "HEAD", key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", client.authorization(req))
+	result, err := client.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+	if result.StatusCode != http.StatusOK {
+		return nil, statusError(result.StatusCode, fmt.Errorf("stat file fail, status code: %d", result.StatusCode))
+	}
+
+	object := &oss.Object{
+		Path:             key,
+		Name:             filepath.Base(key),
+		Size:             result.ContentLength,
+		ContentType:      result.Header.Get("Content-Type"),
+		ETag:             strings.Trim(result.Header.Get("ETag"), `"`),
+		StorageInterface: client,
+	}
+	if lastModified, err := http.ParseTime(result.Header.Get("Last-Modified")); err == nil {
+		object.LastModified = &lastModified
+	}
+	return object, nil
+}
+
+// listBucketResult result of COS API `GET /` (GET Bucket)
+type listBucketResult struct {
+	IsTruncated    bool
+	NextMarker     string
+	Contents       []listBucketContent
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+type listBucketContent struct {
+	Key          string
+	LastModified time.Time
+	ETag         string
+	Size         int64
+}
+
+// List list all objects under current path
 func (client Client) List(path string) ([]*oss.Object, error) {
+	return client.ListContext(context.Background(), path)
+}
+
+// ListContext list all objects under current path
+func (client Client) ListContext(ctx context.Context, path string) ([]*oss.Object, error) {
 	var objects []*oss.Object
+	options := &oss.ListOptions{}
 
-	results, err := client.Get(path)
+	for {
+		result, err := client.ListWithOptionsContext(ctx, path, options)
+		if err != nil {
+			return objects, err
+		}
+		objects = append(objects, result.Objects...)
 
-	if err == nil {
-		objects = append(objects, &oss.Object{
-			Path: client.ToRelativePath(path),
-			Name: results.Name(),
-			//LastModified:     &obj.LastModified,
+		if result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		options.ContinuationToken = result.NextContinuationToken
+	}
+}
+
+// ListWithOptions list a page of objects under current path
+func (client Client) ListWithOptions(path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	return client.ListWithOptionsContext(context.Background(), path, options)
+}
+
+// ListWithOptionsContext list a page of objects under current path with COS API `GET /`,
+// the continuation token is the marker of the next page
+func (client Client) ListWithOptionsContext(ctx context.Context, path string, options *oss.ListOptions) (*oss.ListResult, error) {
+	if options == nil {
+		options = &oss.ListOptions{}
+	}
+
+	query := url.Values{}
+	if prefix := strings.Trim(client.ToRelativePath(path), "/"); prefix != "" {
+		query.Set("prefix", prefix+"/")
+	}
+	if options.ContinuationToken != "" {
+		query.Set("marker", options.ContinuationToken)
+	} else if options.StartAfter != "" {
+		query.Set("marker", client.ToRelativePath(options.StartAfter))
+	}
+	if options.PageSize > 0 {
+		query.Set("max-keys", strconv.Itoa(options.PageSize))
+	}
+	if options.Delimiter != "" {
+		query.Set("delimiter", options.Delimiter)
+	}
+
+	req, err := client.newRequest(ctx, "GET", "", query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var results listBucketResult
+	if _, err := client.doRequest(req, &results); err != nil {
+		return nil, err
+	}
+
+	result := &oss.ListResult{}
+	for _, content := range results.Contents {
+		lastModified := content.LastModified
+		result.Objects = append(result.Objects, &oss.Object{
+			Path:             "/" + content.Key,
+			Name:             filepath.Base(content.Key),
+			LastModified:     &lastModified,
+			Size:             content.Size,
+			ETag:             strings.Trim(content.ETag, `"`),
 			StorageInterface: client,
 		})
-
 	}
-	return objects, err
+	for _, commonPrefix := range results.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, "/"+commonPrefix.Prefix)
+	}
+	if results.IsTruncated {
+		// NextMarker is only returned when delimiter is specified, the last key is the marker otherwise
+		result.NextContinuationToken = results.NextMarker
+		if result.NextContinuationToken == "" && len(results.Contents) > 0 {
+			result.NextContinuationToken = results.Contents[len(results.Contents)-1].Key
+		}
+	}
+
+	return result, nil
 }
 
 func (client Client) GetEndpoint() string {
 	if client.Config.Endpoint != "" {
 		return client.Config.Endpoint
 	}
-	return fmt.Sprintf("%s.cos.%s.myqcloud.com", client.Config.Bucket, client.Config.Region)
+	return client.originHost()
 }
 
 func (client Client) GetURL(path string) (string, error) {
-	return fmt.Sprintf("%s%s", client.getUrl(), client.ToRelativePath(path)), nil
+	return client.GetURLContext(context.Background(), path)
+}
+
+// GetURLContext get an URL presigned for Config.URLExpiry if Config.ACL is private, or the plain URL of the object otherwise,
+// the URL is on Config.Endpoint if it's specified
+func (client Client) GetURLContext(ctx context.Context, path string) (string, error) {
+	if client.Config.ACL != "private" {
+		return fmt.Sprintf("%s%s", client.publicUrl(), client.ToRelativePath(path)), nil
+	}
+	return client.presignURL(client.publicUrl(), http.MethodGet, path, client.urlExpiry(), nil, http.Header{})
+}
+
+func (client Client) urlExpiry() time.Duration {
+	if client.Config.URLExpiry > 0 {
+		return client.Config.URLExpiry
+	}
+	return oss.DefaultURLExpiry
+}
+
+func (client Client) GetUploadURL(path string, expiry time.Duration, contentType string) (string, error) {
+	return client.GetUploadURLContext(context.Background(), path, expiry, contentType)
+}
+
+// GetUploadURLContext get an URL to upload file with PUT request, it's signed in the query string
+func (client Client) GetUploadURLContext(ctx context.Context, path string, expiry time.Duration, contentType string) (string, error) {
+	if expiry <= 0 {
+		expiry = oss.DefaultURLExpiry
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return client.presignURL(client.getUrl(), http.MethodPut, path, expiry, nil, header)
+}
+
+func (client Client) GetURLWithOptions(path string, options *oss.URLOptions) (string, error) {
+	return client.GetURLWithOptionsContext(context.Background(), path, options)
+}
+
+// GetURLWithOptionsContext get an URL signed in the query string if any option is specified
+func (client Client) GetURLWithOptionsContext(ctx context.Context, path string, options *oss.URLOptions) (string, error) {
+	if options == nil || *options == (oss.URLOptions{}) {
+		return client.GetURLContext(ctx, path)
+	}
+	if options.IP != "" || options.Referer != "" {
+		return "", oss.NewError(oss.ErrUnsupported, errors.New("tencent cos doesn't support IP or referer restriction of signed URL"))
+	}
+
+	query := url.Values{}
+	if contentDisposition := options.ContentDisposition(path); contentDisposition != "" {
+		query.Set("response-content-disposition", contentDisposition)
+	}
+	if options.ResponseContentType != "" {
+		query.Set("response-content-type", options.ResponseContentType)
+	}
+	return client.presignURL(client.publicUrl(), http.MethodGet, path, options.GetExpiry(), query, http.Header{})
+}
+
+// presignURL sign a request to baseURL in the query string, query are signed with the request,
+// header are the headers which the request has to be sent with. The host is only signed for the COS domain,
+// as custom domains forward requests to COS with its own host
+func (client Client) presignURL(baseURL string, method string, path string, expiry time.Duration, query url.Values, header http.Header) (string, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", baseURL, client.ToRelativePath(path)), nil)
+	if err != nil {
+		return "", err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header = header
+	if baseURL == client.getUrl() {
+		req.Header.Set("Host", req.URL.Host)
+	}
+
+	now := time.Now()
+	signTime := fmt.Sprintf("%d;%d", now.Unix(), now.Add(expiry).Unix())
+	// semicolons separating sign time and key lists are escaped, which aren't allowed in query strings by many parsers
+	authorization := strings.ReplaceAll(client.signAuthorization(req, signTime), ";", "%3B")
+	if req.URL.RawQuery != "" {
+		req.URL.RawQuery += "&" + authorization
+	} else {
+		req.URL.RawQuery = authorization
+	}
+	return req.URL.String(), nil
+}
+
+// setPutHeaders set the headers of PutOptions to a COS request, Config.ACL is used if options don't specify ACL
+func (client Client) setPutHeaders(header http.Header, options *oss.PutOptions) {
+	if client.Config.ACL != "" {
+		header.Set("x-cos-acl", client.Config.ACL)
+	}
+	if options == nil {
+		return
+	}
+
+	if options.ContentType != "" {
+		header.Set("Content-Type", options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		header.Set("Content-Disposition", options.ContentDisposition)
+	}
+	if options.CacheControl != "" {
+		header.Set("Cache-Control", options.CacheControl)
+	}
+	if options.ContentEncoding != "" {
+		header.Set("Content-Encoding", options.ContentEncoding)
+	}
+	for key, value := range options.Metadata {
+		header.Set("x-cos-meta-"+key, value)
+	}
+	if options.ACL != "" {
+		header.Set("x-cos-acl", options.ACL)
+	}
+}
+
+// statusError wrap err with oss errors according to the status code of COS response
+func statusError(code int, err error) error {
+	switch code {
+	case http.StatusNotFound:
+		return oss.NewError(oss.ErrNotFound, err)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return oss.NewError(oss.ErrPermission, err)
+	case http.StatusConflict:
+		return oss.NewError(oss.ErrAlreadyExists, err)
+	}
+	return err
 }
 
 func (client Client) authorization(req *http.Request) string {
-	signTime := getSignTime()
+	return client.signAuthorization(req, getSignTime())
+}
+
+// signAuthorization sign the method, path, query and headers of req, the signature is valid during signTime
+func (client Client) signAuthorization(req *http.Request, signTime string) string {
 	signature := getSignature(client.Config.AccessKey, req, signTime)
 	authStr := fmt.Sprintf("q-sign-algorithm=sha1&q-ak=%s&q-sign-time=%s&q-key-time=%s&q-header-list=%s&q-url-param-list=%s&q-signature=%s",
 		client.Config.AccessID, signTime, signTime, getHeadKeys(req.Header), getParamsKeys(req.URL.RawQuery), signature)
 
 	return authStr
 }
+
+// newRequest create a COS request of the object at path, rawQuery is the query of the API
+func (client Client) newRequest(ctx context.Context, method string, path string, rawQuery string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", client.getUrl(), client.ToRelativePath(path)), body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = rawQuery
+	req.Header.Set("Host", req.URL.Host)
+	return req, nil
+}
+
+// doRequest sign and send req, the XML response is decoded into result if it isn't nil
+func (client Client) doRequest(req *http.Request, result interface{}) (http.Header, error) {
+	req.Header.Set("Authorization", client.authorization(req))
+	resp, err := client.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		d, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, statusError(resp.StatusCode, errors.New(string(d)))
+	}
+	if result != nil {
+		if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Header, nil
+}
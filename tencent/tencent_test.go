@@ -15,46 +15,599 @@
 package tencent
 
 import (
-	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/casdoor/oss"
+	"github.com/casdoor/oss/internal/s3multipart"
 	"github.com/casdoor/oss/tests"
+	"github.com/jinzhu/configor"
 )
 
-func TestClient_Get(t *testing.T) {
-
+// testConfig config of a COS bucket to run TestAll against, loaded from the environment with prefix TENCENT
+type testConfig struct {
+	AppID     string
+	AccessID  string
+	AccessKey string
+	Bucket    string
+	Region    string
+	Endpoint  string
 }
 
 var client *Client
 
 func init() {
+	config := testConfig{}
+	configor.New(&configor.Config{ENVPrefix: "TENCENT"}).Load(&config)
+	if len(config.AccessID) == 0 {
+		return
+	}
+
 	client = New(&Config{
-		AppID:     "1252882253",
-		AccessID:  "AKIDToxukQWBG8nGXcBN8i662nOo12sc5Wjl",
-		AccessKey: "40jNrBf5mLiuuiU8HH7lDTXP5at00sbA",
-		Bucket:    "tets-1252882253",
-		Region:    "ap-shanghai",
-		ACL:       "public-read", // private，public-read-write，public-read；默认值：private
-		//Endpoint:  config.Public.Endpoint,
+		AppID:     config.AppID,
+		AccessID:  config.AccessID,
+		AccessKey: config.AccessKey,
+		Bucket:    config.Bucket,
+		Region:    config.Region,
+		ACL:       "public-read",
+		Endpoint:  config.Endpoint,
 	})
 }
 
-func TestClient_Put(t *testing.T) {
-	f, err := ioutil.ReadFile("/home/owen/Downloads/2.png")
+func TestAll(t *testing.T) {
+	if client == nil {
+		t.Skip(`skip because of no config:
+
+
+			`)
+	}
+	tests.TestAll(client, t)
+}
+
+// rewriteTransport send requests to server instead of COS, the Host header is kept
+type rewriteTransport struct {
+	server *httptest.Server
+}
+
+func (transport rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(transport.server.URL)
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme, req.URL.Host = u.Scheme, u.Host
+	return transport.server.Client().Transport.RoundTrip(req)
+}
+
+// newFakeCOS a fake COS of a bucket with access key "id" and secret key "key", handler serves requests
+// whose signature in the Authorization header or the query string is valid
+func newFakeCOS(t *testing.T, config *Config, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validSignature(r, "id", "key") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	config.AccessID, config.AccessKey = "id", "key"
+	config.Bucket, config.Region = "bucket-1250000000", "ap-shanghai"
+	client := New(config)
+	client.Client = &http.Client{Transport: rewriteTransport{server: server}}
+	return client
+}
+
+// validSignature verify the signature of r with the headers and parameters listed in it
+func validSignature(r *http.Request, accessID, accessKey string) bool {
+	query := r.URL.Query()
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		authorization = r.URL.RawQuery
+	}
+	// url.ParseQuery rejects the semicolons in q-sign-time
+	values := url.Values{}
+	for _, pair := range strings.Split(authorization, "&") {
+		key, value, _ := strings.Cut(pair, "=")
+		if value, err := url.QueryUnescape(value); err == nil {
+			values.Set(key, value)
+		}
+	}
+	if values.Get("q-ak") != accessID {
+		return false
+	}
+	if signTime := strings.Split(values.Get("q-sign-time"), ";"); len(signTime) != 2 || signTime[1] < strconv.FormatInt(time.Now().Unix(), 10) {
+		return false
+	}
+
+	signed := &http.Request{Method: r.Method, URL: &url.URL{Path: r.URL.Path}, Header: http.Header{}}
+	for _, key := range strings.Split(values.Get("q-header-list"), ";") {
+		if key == "host" {
+			signed.Header.Set(key, r.Host)
+		} else if key != "" {
+			signed.Header.Set(key, r.Header.Get(key))
+		}
+	}
+	// names of parameters are lowercased in the list
+	params, signedParams := url.Values{}, strings.Split(values.Get("q-url-param-list"), ";")
+	for key := range query {
+		for _, signedKey := range signedParams {
+			if strings.ToLower(key) == signedKey {
+				params[key] = query[key]
+			}
+		}
+	}
+	signed.URL.RawQuery = params.Encode()
+	return values.Get("q-signature") == getSignature(accessKey, signed, values.Get("q-sign-time"))
+}
+
+// newFakeBucket a fake of COS GET Bucket API listing keys, which are sorted
+func newFakeBucket(t *testing.T, keys []string) *Client {
+	return newFakeCOS(t, &Config{}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var (
+			query     = r.URL.Query()
+			prefix    = query.Get("prefix")
+			delimiter = query.Get("delimiter")
+			maxKeys   = 1000
+			result    listBucketResult
+			count     int
+			last      string
+		)
+		if query.Get("max-keys") != "" {
+			maxKeys, _ = strconv.Atoi(query.Get("max-keys"))
+		}
+		for _, key := range keys {
+			if !strings.HasPrefix(key, prefix) || key <= query.Get("marker") {
+				continue
+			}
+			commonPrefix := ""
+			if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+				if commonPrefix = key[:len(prefix)+i+len(delimiter)]; commonPrefix == last || strings.HasPrefix(query.Get("marker"), commonPrefix) {
+					continue
+				}
+			}
+			if count == maxKeys {
+				result.IsTruncated = true
+				if delimiter != "" {
+					result.NextMarker = last
+				}
+				break
+			}
+			if commonPrefix != "" {
+				result.CommonPrefixes = append(result.CommonPrefixes, struct{ Prefix string }{commonPrefix})
+				last = commonPrefix
+			} else {
+				result.Contents = append(result.Contents, listBucketContent{Key: key, ETag: `"etag"`, Size: int64(len(key)), LastModified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)})
+				last = key
+			}
+			count++
+		}
+		xml.NewEncoder(w).Encode(result)
+	})
+}
+
+func TestListWithOptions(t *testing.T) {
+	client := newFakeBucket(t, []string{"a.txt", "dir/a.txt", "dir/b.txt", "dir/sub/c.txt", "dir2/d.txt"})
+
+	objects, err := client.List("/dir")
 	if err != nil {
-		t.Error(err)
-		return
+		t.Fatalf("No error should happen when list objects, but got %v", err)
+	}
+	var paths []string
+	for _, object := range objects {
+		paths = append(paths, object.Path)
+	}
+	if strings.Join(paths, ",") != "/dir/a.txt,/dir/b.txt,/dir/sub/c.txt" {
+		t.Errorf("Should list objects under dir, but got %v", paths)
+	}
+	if object := objects[0]; object.Name != "a.txt" || object.Size != 9 || object.ETag != "etag" || object.LastModified == nil || object.LastModified.Year() != 2024 {
+		t.Errorf("Listed object should have its metadata, but got %+v", object)
+	}
+
+	paths = nil
+	options := &oss.ListOptions{PageSize: 1}
+	for {
+		result, err := client.ListWithOptions("/", options)
+		if err != nil {
+			t.Fatalf("No error should happen when list objects page by page, but got %v", err)
+		}
+		for _, object := range result.Objects {
+			paths = append(paths, object.Path)
+		}
+		if result.NextContinuationToken == "" {
+			break
+		}
+		options.ContinuationToken = result.NextContinuationToken
+	}
+	if len(paths) != 5 {
+		t.Errorf("Should list all objects page by page, but got %v", paths)
 	}
 
-	client.Put("test.png", bytes.NewReader(f))
+	result, err := client.ListWithOptions("/", &oss.ListOptions{Delimiter: "/", StartAfter: "/a.txt"})
+	if err != nil {
+		t.Fatalf("No error should happen when list objects with delimiter, but got %v", err)
+	} else if len(result.Objects) != 0 || strings.Join(result.CommonPrefixes, ",") != "/dir/,/dir2/" {
+		t.Errorf("Should list common prefixes after a.txt with delimiter, but got %v %v", result.Objects, result.CommonPrefixes)
+	}
 }
 
-func TestClient_Put2(t *testing.T) {
-	tests.TestAll(client, t)
+// newFakeObjects a fake COS storing objects in memory, ACLs of uploaded objects and CORS rules of the bucket are recorded
+func newFakeObjects(t *testing.T, config *Config) (*Client, map[string]string, map[string]string) {
+	objects, acls := map[string]string{}, map[string]string{}
+	var mutex sync.Mutex
+	client := newFakeCOS(t, config, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/" && r.URL.RawQuery == "cors":
+			body, _ := ioutil.ReadAll(r.Body)
+			sum := md5.Sum(body)
+			if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects["?cors"] = string(body)
+		case r.Method == "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			objects[r.URL.Path], acls[r.URL.Path] = string(body), r.Header.Get("x-cos-acl")
+		case r.Method == "GET":
+			content, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if contentType := r.URL.Query().Get("response-content-type"); contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			fmt.Fprint(w, content)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	return client, objects, acls
+}
+
+func TestGetRangeIgnored(t *testing.T) {
+	// the fake server ignores Range and responds the whole object, like some proxies
+	client, objects, _ := newFakeObjects(t, &Config{})
+	objects["/range.txt"] = "0123456789"
+
+	for _, c := range []struct {
+		offset, length int64
+		want           string
+	}{{2, 3, "234"}, {5, 0, "56789"}, {0, 20, "0123456789"}} {
+		stream, err := client.GetRange("/range.txt", c.offset, c.length)
+		if err != nil {
+			t.Fatalf("No error should happen when get range, but got %v", err)
+		}
+		if content, _ := ioutil.ReadAll(stream); string(content) != c.want {
+			t.Errorf("Range %v+%v should be %q, but got %q", c.offset, c.length, c.want, content)
+		}
+		stream.Close()
+	}
+	if _, err := client.GetRange("/range.txt", 20, 1); err == nil {
+		t.Errorf("Error should happen when range starts beyond the object")
+	}
+}
+
+func TestPrivateBucket(t *testing.T) {
+	client, _, acls := newFakeObjects(t, &Config{ACL: "private", URLExpiry: time.Minute})
+
+	if _, err := client.Put("/private.txt", strings.NewReader("private")); err != nil {
+		t.Fatalf("No error should happen when put object, but got %v", err)
+	}
+	if acl := acls["/private.txt"]; acl != "private" {
+		t.Errorf("Object should be uploaded with private ACL, but got %v", acl)
+	}
+
+	if stream, err := client.GetStream("/private.txt"); err != nil {
+		t.Errorf("No error should happen when get object with signed request, but got %v", err)
+	} else if content, _ := ioutil.ReadAll(stream); string(content) != "private" {
+		t.Errorf("Content of object should be private, but got %v", string(content))
+	}
+
+	rawURL, err := client.GetURL("/private.txt")
+	if err != nil {
+		t.Fatalf("No error should happen when get URL, but got %v", err)
+	}
+	u, _ := url.Parse(rawURL)
+	if u.Host != "bucket-1250000000.cos.ap-shanghai.myqcloud.com" || u.Path != "/private.txt" {
+		t.Errorf("URL should be of the object, but got %v", rawURL)
+	}
+	if signTime := strings.Split(u.Query().Get("q-sign-time"), ";"); len(signTime) != 2 {
+		t.Errorf("URL should be presigned, but got %v", rawURL)
+	} else if start, _ := strconv.ParseInt(signTime[0], 10, 64); signTime[1] != strconv.FormatInt(start+60, 10) {
+		t.Errorf("URL should expire after URLExpiry, but got %v", rawURL)
+	}
+	if resp, err := client.Client.Get(rawURL); err != nil {
+		t.Errorf("No error should happen when get presigned URL, but got %v", err)
+	} else if content, _ := ioutil.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(content) != "private" {
+		t.Errorf("Presigned URL should be readable, but got %v %v", resp.StatusCode, string(content))
+	}
+
+	// unsigned requests are rejected
+	if resp, err := client.Client.Get("http://" + u.Host + u.Path); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Unsigned request should be forbidden, but got %v %v", resp, err)
+	}
+
+	// presigning is opted in with the private ACL
+	client.Config.ACL = ""
+	if rawURL, err := client.GetURL("/private.txt"); err != nil || rawURL != "https://bucket-1250000000.cos.ap-shanghai.myqcloud.com/private.txt" {
+		t.Errorf("URL shouldn't be presigned without the private ACL, but got %v %v", rawURL, err)
+	}
+}
+
+func TestGetURLWithOptionsPresigned(t *testing.T) {
+	client, objects, _ := newFakeObjects(t, &Config{ACL: "public-read"})
+	objects["/sample.txt"] = "sample"
+
+	rawURL, err := client.GetURLWithOptions("/sample.txt", &oss.URLOptions{Expiry: time.Minute, Download: true, ResponseContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("No error should happen when get URL with options, but got %v", err)
+	}
+	u, _ := url.Parse(rawURL)
+	query := u.Query()
+	if !strings.Contains(query.Get("response-content-disposition"), "attachment") || query.Get("response-content-type") != "text/plain" {
+		t.Errorf("URL should override the response, but got %v", rawURL)
+	}
+	if query.Get("q-sign-algorithm") != "sha1" || query.Get("q-ak") != "id" || query.Get("q-url-param-list") != "response-content-disposition;response-content-type" {
+		t.Errorf("Parameters overriding the response should be signed, but got %v", rawURL)
+	}
+	if signTime := strings.Split(query.Get("q-sign-time"), ";"); len(signTime) != 2 {
+		t.Errorf("URL should be presigned, but got %v", rawURL)
+	} else if start, _ := strconv.ParseInt(signTime[0], 10, 64); signTime[1] != strconv.FormatInt(start+60, 10) {
+		t.Errorf("URL should expire after Expiry, but got %v", rawURL)
+	}
+	if resp, err := client.Client.Get(rawURL); err != nil {
+		t.Errorf("No error should happen when get presigned URL, but got %v", err)
+	} else if content, _ := ioutil.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(content) != "sample" || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Presigned URL should be readable with the response overridden, but got %v %v", resp.StatusCode, string(content))
+	}
+
+	uploadURL, err := client.GetUploadURL("/upload.txt", time.Minute, "text/plain")
+	if err != nil {
+		t.Fatalf("No error should happen when get upload URL, but got %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, uploadURL, strings.NewReader("upload"))
+	req.Header.Set("Content-Type", "text/plain")
+	if resp, err := client.Client.Do(req); err != nil || resp.StatusCode != http.StatusOK || objects["/upload.txt"] != "upload" {
+		t.Errorf("Object should be uploaded with the upload URL, but got %v %v", resp, err)
+	}
+}
+
+func TestGetUploadURLDefaultExpiry(t *testing.T) {
+	client, _, _ := newFakeObjects(t, &Config{})
+	for _, expiry := range []time.Duration{0, -time.Minute} {
+		rawURL, err := client.GetUploadURL("/upload.txt", expiry, "text/plain")
+		if err != nil {
+			t.Fatalf("No error should happen when get upload URL, but got %v", err)
+		}
+		u, _ := url.Parse(rawURL)
+		if signTime := strings.Split(u.Query().Get("q-sign-time"), ";"); len(signTime) != 2 {
+			t.Errorf("Upload URL should be presigned, but got %v", rawURL)
+		} else if start, _ := strconv.ParseInt(signTime[0], 10, 64); signTime[1] != strconv.FormatInt(start+3600, 10) {
+			t.Errorf("Upload URL with expiry %v should expire after an hour, but got %v", expiry, rawURL)
+		}
+	}
 }
 
-func TestClient_Delete(t *testing.T) {
-	fmt.Println(client.Delete("test.png"))
+func TestPublicBucket(t *testing.T) {
+	client, _, acls := newFakeObjects(t, &Config{ACL: "public-read", CORS: "https://a.example.com, https://b.example.com"})
+
+	if _, err := client.Put("/public.txt", strings.NewReader("public")); err != nil {
+		t.Fatalf("No error should happen when put object, but got %v", err)
+	}
+	if _, err := client.PutWithOptions("/private.txt", strings.NewReader("private"), &oss.PutOptions{ACL: "private"}); err != nil {
+		t.Fatalf("No error should happen when put object with options, but got %v", err)
+	}
+	if acls["/public.txt"] != "public-read" || acls["/private.txt"] != "private" {
+		t.Errorf("Objects should be uploaded with Config.ACL unless overridden, but got %v", acls)
+	}
+
+	if rawURL, err := client.GetURL("/public.txt"); err != nil || rawURL != "https://bucket-1250000000.cos.ap-shanghai.myqcloud.com/public.txt" {
+		t.Errorf("URL of public object should be plain, but got %v %v", rawURL, err)
+	}
+}
+
+func TestPutBucketCORS(t *testing.T) {
+	client, objects, _ := newFakeObjects(t, &Config{CORS: "https://a.example.com, https://b.example.com"})
+	if err := client.PutBucketCORS(); err != nil {
+		t.Fatalf("No error should happen when put CORS rules, but got %v", err)
+	}
+
+	var cors corsConfiguration
+	if err := xml.Unmarshal([]byte(objects["?cors"]), &cors); err != nil || len(cors.Rules) != 1 {
+		t.Fatalf("A CORS rule should be put, but got %v %v", objects["?cors"], err)
+	} else if origins := cors.Rules[0].AllowedOrigins; strings.Join(origins, ",") != "https://a.example.com,https://b.example.com" {
+		t.Errorf("CORS rule should allow origins of Config.CORS, but got %v", origins)
+	}
+
+	client.Config.CORS = ""
+	if err := client.PutBucketCORS(); err == nil {
+		t.Errorf("There should be an error when no origin is configured")
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	var hosts []string
+	client, _, _ := newFakeObjects(t, &Config{ACL: "private", Accelerate: true, Endpoint: "cdn.example.com"})
+	transport := client.Client.Transport
+	client.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Scheme+"://"+req.URL.Host)
+		return transport.RoundTrip(req)
+	})}
+
+	if _, err := client.Put("/sample.txt", strings.NewReader("sample")); err != nil {
+		t.Errorf("No error should happen when put object, but got %v", err)
+	}
+	if _, err := client.GetStream("/sample.txt"); err != nil {
+		t.Errorf("No error should happen when get object, but got %v", err)
+	}
+	if strings.Join(hosts, ",") != "https://bucket-1250000000.cos.accelerate.myqcloud.com,https://bucket-1250000000.cos.accelerate.myqcloud.com" {
+		t.Errorf("API requests should be sent to the acceleration domain with https, but got %v", hosts)
+	}
+
+	if rawURL, err := client.GetURL("/sample.txt"); err != nil {
+		t.Errorf("No error should happen when get URL, but got %v", err)
+	} else if u, _ := url.Parse(rawURL); u.Scheme+"://"+u.Host+u.Path != "https://cdn.example.com/sample.txt" || u.Query().Get("q-signature") == "" {
+		t.Errorf("URL should be presigned on the custom domain, but got %v", rawURL)
+	} else if u.Query().Get("q-header-list") != "" {
+		t.Errorf("Host of the custom domain shouldn't be signed, but got %v", rawURL)
+	}
+	if rawURL, err := client.GetUploadURL("/sample.txt", time.Minute, ""); err != nil || !strings.HasPrefix(rawURL, "https://bucket-1250000000.cos.accelerate.myqcloud.com/sample.txt?") {
+		t.Errorf("Upload URL should be on the acceleration domain, but got %v %v", rawURL, err)
+	}
+
+	for _, config := range []*Config{
+		{Bucket: "bucket", Region: "ap-guangzhou", ACL: "public-read", DisableHTTPS: true},
+		{Bucket: "bucket", Region: "ap-guangzhou", ACL: "public-read", Endpoint: "http://cdn.example.com/"},
+	} {
+		if rawURL, _ := New(config).GetURL("sample.txt"); !strings.HasPrefix(rawURL, "http://") || !strings.HasSuffix(rawURL, "/sample.txt") {
+			t.Errorf("URL should use plain http with %+v, but got %v", config, rawURL)
+		}
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// fakeUploads multipart uploads of a fake COS, which fails to upload part failPart if it's positive
+type fakeUploads struct {
+	mutex    sync.Mutex
+	objects  map[string]string
+	headers  map[string]http.Header
+	uploads  map[string]map[int]string
+	failPart int
+}
+
+func (uploads *fakeUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uploads.mutex.Lock()
+	defer uploads.mutex.Unlock()
+
+	query := r.URL.Query()
+	parts, ok := uploads.uploads[query.Get("uploadId")]
+	switch {
+	case r.Method == "POST" && r.URL.RawQuery == "uploads":
+		uploadID := strconv.Itoa(len(uploads.uploads) + 1)
+		uploads.uploads[uploadID], uploads.headers[r.URL.Path] = map[int]string{}, r.Header
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+	case r.Method == "PUT" && query.Get("uploadId") != "":
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		if !ok || number == uploads.failPart {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		parts[number] = string(body)
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == "POST" && ok:
+		var complete s3multipart.CompleteUpload
+		xml.NewDecoder(r.Body).Decode(&complete)
+		var content string
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			content += parts[part.PartNumber]
+		}
+		uploads.objects[r.URL.Path] = content
+		delete(uploads.uploads, query.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult><ETag>\"etag\"</ETag></CompleteMultipartUploadResult>")
+	case r.Method == "DELETE" && ok:
+		delete(uploads.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT":
+		body, _ := ioutil.ReadAll(r.Body)
+		uploads.objects[r.URL.Path], uploads.headers[r.URL.Path] = string(body), r.Header
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPutMultipart(t *testing.T) {
+	uploads := &fakeUploads{objects: map[string]string{}, headers: map[string]http.Header{}, uploads: map[string]map[int]string{}}
+	client := newFakeCOS(t, &Config{ACL: "public-read", PartSize: 4, Concurrency: 2}, uploads.ServeHTTP)
+
+	// readers which aren't buffers are streamed in parts
+	content := "0123456789abcdefghij-"
+	object, err := client.PutWithOptions("/large.txt", ioutil.NopCloser(strings.NewReader(content)), &oss.PutOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("No error should happen when put object in parts, but got %v", err)
+	}
+	if uploads.objects["/large.txt"] != content || object.Size != int64(len(content)) {
+		t.Errorf("Object should be combined from parts, but got %q of size %v", uploads.objects["/large.txt"], object.Size)
+	}
+	if header := uploads.headers["/large.txt"]; header.Get("Content-Type") != "text/plain" || header.Get("x-cos-acl") != "public-read" {
+		t.Errorf("Multipart upload should be initiated with options and ACL, but got %v", header)
+	}
+
+	// small objects are uploaded with a single request
+	if _, err := client.Put("/small.txt", strings.NewReader("0123")); err != nil || uploads.objects["/small.txt"] != "0123" {
+		t.Errorf("Small object should be put, but got %q %v", uploads.objects["/small.txt"], err)
+	} else if len(uploads.headers) != 2 {
+		t.Errorf("Small object shouldn't be uploaded in parts, but got %v", uploads.headers)
+	}
+
+	uploads.failPart = 3
+	if _, err := client.Put("/failed.txt", strings.NewReader(content)); err == nil {
+		t.Errorf("There should be an error when a part fails")
+	}
+	if _, ok := uploads.objects["/failed.txt"]; ok || len(uploads.uploads) != 0 {
+		t.Errorf("Failed upload should be aborted, but got %v uploads", len(uploads.uploads))
+	}
+}
+
+func TestPutMultipartPartSize(t *testing.T) {
+	uploads := &fakeUploads{objects: map[string]string{}, headers: map[string]http.Header{}, uploads: map[string]map[int]string{}}
+	var initiated int
+	client := newFakeCOS(t, &Config{PartSize: 4}, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.RawQuery == "uploads" {
+			initiated++
+		}
+		uploads.ServeHTTP(w, r)
+	})
+
+	for _, c := range []struct {
+		content   string
+		multipart bool
+	}{
+		{"", false},
+		{"012", false},
+		{"0123", false},
+		{"01234", true},
+		{"01234567", true},
+	} {
+		before := initiated
+		path := "/" + strconv.Itoa(len(c.content)) + ".txt"
+		object, err := client.Put(path, ioutil.NopCloser(strings.NewReader(c.content)))
+		if err != nil {
+			t.Errorf("No error should happen when put %q, but got %v", c.content, err)
+			continue
+		}
+		if multipart := initiated > before; multipart != c.multipart {
+			t.Errorf("Object of %v bytes should be uploaded in parts (%v) with PartSize 4, but got %v", len(c.content), c.multipart, multipart)
+		}
+		if uploads.objects[path] != c.content || object.Size != int64(len(c.content)) {
+			t.Errorf("Object should be %q, but got %q of size %v", c.content, uploads.objects[path], object.Size)
+		}
+	}
 }
@@ -15,7 +15,11 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -36,12 +40,15 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 	exceptObjects := 2
 	sampleFile, _ := filepath.Abs("../tests/sample.txt")
 
-	// Put file
+	// Put file, paths of put objects should be the same as listed ones
+	putPaths := map[string]bool{}
 	if file, err := os.Open(sampleFile); err == nil {
 		if object, err := storage.Put(fileName, file); err != nil {
 			t.Errorf("No error should happen when save sample file, but got %v", err)
 		} else if object.Path == "" || object.StorageInterface == nil {
 			t.Errorf("returned object should necessary information")
+		} else {
+			putPaths[object.Path] = true
 		}
 	} else {
 		t.Errorf("No error should happen when opem sample file, but got %v", err)
@@ -52,6 +59,8 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 			t.Errorf("No error should happen when save sample file, but got %v", err)
 		} else if object.Path == "" || object.StorageInterface == nil {
 			t.Errorf("returned object should necessary information")
+		} else {
+			putPaths[object.Path] = true
 		}
 	} else {
 		t.Errorf("No error should happen when opem sample file, but got %v", err)
@@ -85,6 +94,19 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 		}
 	}
 
+	// GetURL with options
+	if urlOptionGetter, ok := storage.(oss.URLOptionGetter); ok {
+		if url, err := urlOptionGetter.GetURLWithOptions(fileName, &oss.URLOptions{Expiry: time.Minute, Download: true}); err != nil {
+			t.Errorf("No error should happen when GetURL with options for sample file, but got %v", err)
+		} else if strings.HasPrefix(url, "http") {
+			if resp, err := http.Get(url); err != nil {
+				t.Errorf("No error should happen when get file with URL with options, but got %v", err)
+			} else if resp.Body.Close(); !strings.HasPrefix(resp.Header.Get("Content-Disposition"), "attachment") {
+				t.Errorf("File should be downloaded as attachment, but got Content-Disposition %v", resp.Header.Get("Content-Disposition"))
+			}
+		}
+	}
+
 	// Get stream
 	if stream, err := storage.GetStream(fileName); err != nil {
 		t.Errorf("No error should happen when get sample file, but got %v", err)
@@ -96,6 +118,32 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 		}
 	}
 
+	// Get range
+	if rangeGetter, ok := storage.(oss.RangeGetter); ok {
+		content, _ := ioutil.ReadFile(sampleFile)
+		if stream, err := rangeGetter.GetRange(fileName, 1, 3); err != nil {
+			t.Errorf("No error should happen when get range of sample file, but got %v", err)
+		} else {
+			if buffer, err := ioutil.ReadAll(stream); err != nil {
+				t.Errorf("No error should happen when read range of sample file, but got %v", err)
+			} else if string(buffer) != string(content[1:4]) {
+				t.Errorf("Range of sample file should be %v, but got %v", string(content[1:4]), string(buffer))
+			}
+			stream.Close()
+		}
+	}
+
+	// Stat
+	if stater, ok := storage.(oss.Stater); ok {
+		if object, err := stater.Stat(fileName); err != nil {
+			t.Errorf("No error should happen when stat sample file, but got %v", err)
+		} else if info, err := os.Stat(sampleFile); err == nil && object.Size != info.Size() {
+			t.Errorf("Stat should return size %v, but got %v", info.Size(), object.Size)
+		} else if object.LastModified == nil {
+			t.Errorf("Stat should return last modified time")
+		}
+	}
+
 	// List
 	if objects, err := storage.List(randomPath); err != nil {
 		t.Errorf("No error should happen when list objects, but got %v", err)
@@ -104,6 +152,10 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 	} else {
 		var found1, found2 bool
 		for _, object := range objects {
+			if !putPaths[object.Path] {
+				t.Errorf("Path of listed object %v should be the same as the one returned by Put", object.Path)
+			}
+
 			if object.Path == fileName {
 				found1 = true
 			}
@@ -122,6 +174,115 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 		}
 	}
 
+	// Walk
+	var walked int
+	if err := oss.Walk(context.Background(), storage, randomPath, func(object *oss.Object) error {
+		walked++
+		return nil
+	}); err != nil {
+		t.Errorf("No error should happen when walk objects, but got %v", err)
+	} else if walked != exceptObjects {
+		t.Errorf("Should walk %v objects, but got %v", exceptObjects, walked)
+	}
+
+	// List with options
+	if lister, ok := storage.(oss.OptionLister); ok {
+		var paths []string
+		options := &oss.ListOptions{PageSize: 1}
+		for {
+			result, err := lister.ListWithOptions(randomPath, options)
+			if err != nil {
+				t.Errorf("No error should happen when list objects with options, but got %v", err)
+				break
+			}
+			for _, object := range result.Objects {
+				paths = append(paths, object.Path)
+			}
+			if result.NextContinuationToken == "" {
+				break
+			}
+			options.ContinuationToken = result.NextContinuationToken
+		}
+		if len(paths) != exceptObjects {
+			t.Errorf("Should found %v objects page by page, but got %v", exceptObjects, paths)
+		}
+
+		if result, err := lister.ListWithOptions(randomPath, &oss.ListOptions{Delimiter: "/"}); err != nil {
+			t.Errorf("No error should happen when list objects with delimiter, but got %v", err)
+		} else if len(result.Objects) != 1 || result.Objects[0].Path != fileName {
+			t.Errorf("Should found object %v with delimiter, but got %v", fileName, result.Objects)
+		} else if len(result.CommonPrefixes) != 1 || result.CommonPrefixes[0] != filepath.Dir(fileName2)+"/" {
+			t.Errorf("Should found common prefix %v with delimiter, but got %v", filepath.Dir(fileName2)+"/", result.CommonPrefixes)
+		}
+	}
+
+	// Copy and move
+	copyName := "/" + filepath.Join(randomPath, "copy", "sample.txt")
+	moveName := "/" + filepath.Join(randomPath, "move", "moved.txt")
+	if err := oss.Copy(context.Background(), storage, fileName, copyName); err != nil {
+		t.Errorf("No error should happen when copy sample file, but got %v", err)
+	} else if err := oss.Move(context.Background(), storage, copyName, moveName); err != nil {
+		t.Errorf("No error should happen when move copied file, but got %v", err)
+	} else {
+		if _, err := storage.Get(copyName); !errors.Is(err, oss.ErrNotFound) {
+			t.Errorf("Error should be ErrNotFound when get moved file, but got %v", err)
+		}
+		if file, err := storage.Get(moveName); err != nil {
+			t.Errorf("No error should happen when get moved file, but got %v", err)
+		} else if buffer, err := ioutil.ReadAll(file); err != nil {
+			t.Errorf("No error should happen when read moved file, but got %v", err)
+		} else if content, _ := ioutil.ReadFile(sampleFile); string(buffer) != string(content) {
+			t.Errorf("Moved file should contain correct content, but got %v", string(buffer))
+		}
+		if err := storage.Delete(moveName); err != nil {
+			t.Errorf("No error should happen when delete moved file, but got %v", err)
+		}
+	}
+
+	// Upload with presigned URL
+	if uploadURLGetter, ok := storage.(oss.UploadURLGetter); ok {
+		uploadName := "/" + filepath.Join(randomPath, "upload.txt")
+		if uploadURL, err := uploadURLGetter.GetUploadURL(uploadName, time.Hour, "text/plain"); err != nil {
+			t.Errorf("No error should happen when get upload URL, but got %v", err)
+		} else if strings.HasPrefix(uploadURL, "http") {
+			content, _ := ioutil.ReadFile(sampleFile)
+			req, _ := http.NewRequest(http.MethodPut, uploadURL, bytes.NewReader(content))
+			req.Header = oss.GetUploadHeaders(storage, "text/plain")
+			if resp, err := http.DefaultClient.Do(req); err != nil {
+				t.Errorf("No error should happen when upload with presigned URL, but got %v", err)
+			} else if resp.Body.Close(); resp.StatusCode >= 300 {
+				t.Errorf("Upload with presigned URL should succeed, but got status %v", resp.StatusCode)
+			} else if err := storage.Delete(uploadName); err != nil {
+				t.Errorf("No error should happen when delete uploaded file, but got %v", err)
+			}
+		}
+	}
+
+	// Put file with options
+	if putter, ok := storage.(oss.OptionPutter); ok {
+		fileName3 := "/" + filepath.Join(randomPath, "sample3.txt")
+		if file, err := os.Open(sampleFile); err == nil {
+			object, err := putter.PutWithOptions(fileName3, file, &oss.PutOptions{ContentType: "text/plain", Metadata: map[string]string{"key": "value"}})
+			if errors.Is(err, oss.ErrUnsupported) {
+				// options which the storage couldn't store are rejected instead of ignored, empty options are always supported
+				file.Seek(0, io.SeekStart)
+				object, err = putter.PutWithOptions(fileName3, file, &oss.PutOptions{})
+			}
+			if err != nil {
+				t.Errorf("No error should happen when save sample file with options, but got %v", err)
+			} else if object.Path == "" || object.StorageInterface == nil {
+				t.Errorf("returned object should necessary information")
+			}
+			file.Close()
+		} else {
+			t.Errorf("No error should happen when opem sample file, but got %v", err)
+		}
+
+		if err := storage.Delete(fileName3); err != nil {
+			t.Errorf("No error should happen when delete sample file, but got %v", err)
+		}
+	}
+
 	// Delete
 	if err := storage.Delete(fileName); err != nil {
 		t.Errorf("No error should happen when delete sample file, but got %v", err)
@@ -130,6 +291,15 @@ func TestAll(storage oss.StorageInterface, t *testing.T) {
 	// Get file after delete
 	if _, err := storage.Get(fileName); err == nil {
 		t.Errorf("There should be an error when get deleted sample file")
+	} else if !errors.Is(err, oss.ErrNotFound) {
+		t.Errorf("Error should be ErrNotFound when get deleted sample file, but got %v", err)
+	}
+
+	// Stat file after delete
+	if stater, ok := storage.(oss.Stater); ok {
+		if _, err := stater.Stat(fileName); !errors.Is(err, oss.ErrNotFound) {
+			t.Errorf("Error should be ErrNotFound when stat deleted sample file, but got %v", err)
+		}
 	}
 
 	// Get file after delete
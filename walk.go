@@ -0,0 +1,89 @@
+// Copyright 2024 The Casdoor Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oss
+
+import (
+	"context"
+	"errors"
+)
+
+// SkipAll could be returned by a WalkFunc to stop walking, Walk and Walker implementations return nil then
+var SkipAll = errors.New("oss: skip all objects")
+
+// WalkFunc is called for every object under the walked path,
+// returning an error other than SkipAll stops walking and Walk returns it
+type WalkFunc func(object *Object) error
+
+// Walker is implemented by storages which could iterate objects under a path page by page,
+// without loading all of them into memory. WalkContext stops and returns nil if fn returns SkipAll
+type Walker interface {
+	Walk(path string, fn WalkFunc) error
+	WalkContext(ctx context.Context, path string, fn WalkFunc) error
+}
+
+// Walk call fn for every object under path. Walker and OptionLister are used to iterate
+// objects page by page if the storage implements them, otherwise all objects are listed at once
+func Walk(ctx context.Context, storage StorageInterface, path string, fn WalkFunc) error {
+	err := walk(ctx, storage, path, fn)
+	if err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walk(ctx context.Context, storage StorageInterface, path string, fn WalkFunc) error {
+	if walker, ok := storage.(Walker); ok {
+		return walker.WalkContext(ctx, path, fn)
+	}
+
+	if lister, ok := storage.(OptionLister); ok {
+		options := &ListOptions{}
+		for {
+			result, err := lister.ListWithOptionsContext(ctx, path, options)
+			if err != nil {
+				return err
+			}
+			for _, object := range result.Objects {
+				if err := fn(object); err != nil {
+					return err
+				}
+			}
+
+			if result.NextContinuationToken == "" {
+				return nil
+			}
+			options.ContinuationToken = result.NextContinuationToken
+		}
+	}
+
+	var (
+		objects []*Object
+		err     error
+	)
+	if contextStorage, ok := storage.(ContextStorage); ok {
+		objects, err = contextStorage.ListContext(ctx, path)
+	} else {
+		objects, err = storage.List(path)
+	}
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}